@@ -0,0 +1,134 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"math"
+	"net/netip"
+	"strings"
+
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// class categorizes a node by its process or the type and state of its connection.
+	class int
+)
+
+const (
+	classProcess class = iota
+	classTCPListen
+	classTCPEstablished
+	classUDP
+	classUnixStream
+	classUnixDgram
+	classPipe
+	classFIFO
+	classFile
+	classDirectory
+	classDevice
+	classNotify
+	classKernel
+)
+
+var (
+	// classes define for each class the arc for the circle drawn around a node.
+	// Each arc has a specific color set in its field metadata to create a circle that identifies the node type.
+	// The icon is the name of the Grafana icon drawn inside the node.
+	classes = [...]struct {
+		name    string
+		display string
+		color   string
+		icon    string
+	}{
+		classProcess:        {"process", "Process", "#0000ff", "cog"},                   // blue
+		classTCPListen:      {"tcp_listen", "TCP Listen", "#ff00ff", "rss"},             // magenta
+		classTCPEstablished: {"tcp_established", "TCP Established", "#ff0000", "cloud"}, // red
+		classUDP:            {"udp", "UDP", "#ff8c00", "wifi"},                          // orange
+		classUnixStream:     {"unix_stream", "Unix Stream", "#8a2be2", "link"},          // purple
+		classUnixDgram:      {"unix_dgram", "Unix Datagram", "#da70d6", "link"},         // orchid
+		classPipe:           {"pipe", "Pipe", "#008000", "exchange-alt"},                // green
+		classFIFO:           {"fifo", "FIFO", "#90ee90", "exchange-alt"},                // light green
+		classFile:           {"file", "File", "#ffff00", "file-alt"},                    // yellow
+		classDirectory:      {"directory", "Directory", "#daa520", "folder"},            // goldenrod
+		classDevice:         {"device", "Device", "#87cefa", "monitor"},                 // light blue
+		classNotify:         {"notify", "Event/Notify", "#008080", "bell"},              // teal
+		classKernel:         {"kernel", "Kernel", "#00ffff", "bolt"},                    // cyan
+	}
+
+	// notifiers identify the kernel's event and file notification objects.
+	notifiers = []string{"eventfd", "inotify", "fanotify", "kqueue", "fsevents"}
+)
+
+// arcs returns the arc values of a node of a class.
+func arcs(cls class) []any {
+	arcs := make([]any, len(classes))
+	for i := range arcs {
+		arcs[i] = 0.0
+	}
+	arcs[cls] = 1.0
+	return arcs
+}
+
+// nodeClass defines the class for grafana nodes.
+func nodeClass(conn process.Connection) class {
+	if conn.Peer.Pid >= 0 && conn.Peer.Pid < math.MaxInt32 {
+		return classProcess
+	}
+	return classify(conn)
+}
+
+// baseType returns a connection's type without its IP version, e.g. TCP for TCP6.
+func baseType(t string) string {
+	return strings.ToUpper(strings.TrimRight(t, "46"))
+}
+
+// classify determines the class of a connection from its type and socket state.
+func classify(conn process.Connection) class {
+	switch baseType(conn.Type) {
+	case "TCP":
+		if listening(conn) {
+			return classTCPListen
+		}
+		return classTCPEstablished
+	case "UDP":
+		return classUDP
+	case "UNIX":
+		if unixDgram(conn) {
+			return classUnixDgram
+		}
+		return classUnixStream
+	case "PIPE":
+		return classPipe
+	case "FIFO":
+		if strings.HasPrefix(conn.Self.Name, "pipe:") || strings.HasPrefix(conn.Peer.Name, "pipe:") {
+			return classPipe // linux reports anonymous pipes as FIFOs
+		}
+		return classFIFO
+	case "REG":
+		return classFile
+	case "DIR":
+		return classDirectory
+	case "CHR", "BLK":
+		return classDevice
+	}
+
+	kind := strings.ToLower(conn.Type + " " + conn.Peer.Name)
+	for _, notifier := range notifiers {
+		if strings.Contains(kind, notifier) {
+			return classNotify
+		}
+	}
+	return classKernel
+}
+
+// listening reports whether a TCP connection is a listen socket. An established socket has both local and
+// remote ip:port endpoints. A listen socket has only its bound address, its own end named by the socket's
+// device inode, or a remote endpoint of an unspecified address and port 0.
+func listening(conn process.Connection) bool {
+	if _, err := netip.ParseAddrPort(conn.Self.Name); err != nil {
+		return true
+	}
+	peer, err := netip.ParseAddrPort(conn.Peer.Name)
+	return err != nil || peer.Addr().IsUnspecified() && peer.Port() == 0
+}
@@ -3,6 +3,7 @@
 package plugin
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -19,10 +20,24 @@ import (
 )
 
 type (
+	// Settings of the datasource, configured in its jsonData.
+	Settings struct {
+		StreamControl bool              `json:"streamControl"` // permit control messages published to streams
+		MaxNodes      int               `json:"maxNodes"`      // cap on nodes in node graph
+		MaxEdges      int               `json:"maxEdges"`      // cap on edges in node graph
+		OfflineHosts  bool              `json:"offlineHosts"`  // resolve host names only from the hosts file
+		LookupMs      int               `json:"lookupMs"`      // per query deadline for host name lookups
+		Services      map[string]string `json:"services"`      // service names of ports, e.g. {"TCP:5432": "postgresql"}
+		SnapshotDir   string            `json:"snapshotDir"`   // directory of the node graph snapshot store
+		SnapshotMB    int               `json:"snapshotMB"`    // cap on size of the snapshot store
+	}
+
 	// Instance of the datasource.
 	Instance struct {
-		ctx    context.Context
-		Health struct {
+		ctx      context.Context
+		release  func() // releases the instance's use of the sampler
+		settings Settings
+		Health   struct {
 			Checks int `json:"checks"`
 		} `json:"health"`
 		Query struct {
@@ -39,13 +54,11 @@ type (
 	}
 )
 
-var (
-	instance Instance
-)
-
 func Factory(ctx context.Context) datasource.InstanceFactoryFunc {
 	gocore.Error("DataSourceInstanceFactory", nil).Info()
 
+	plugin := ctx // lasts for the life of the plugin, unlike the context of the request that creates an instance
+
 	return func(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 		gocore.Error("create datasource instance", nil, map[string]string{
 			"id":       strconv.Itoa(int(settings.ID)),
@@ -57,22 +70,46 @@ func Factory(ctx context.Context) datasource.InstanceFactoryFunc {
 			settings.DecryptedSecureJSONData,
 		).Info()
 
-		instance.ctx = ctx
+		instance := &Instance{ctx: ctx}
+		if len(settings.JSONData) > 0 {
+			if err := json.Unmarshal(settings.JSONData, &instance.settings); err != nil {
+				return nil, gocore.Error("datasource settings", err, map[string]string{
+					"id":       strconv.Itoa(int(settings.ID)),
+					"jsonData": string(settings.JSONData),
+				})
+			}
+		}
+		instance.settings.MaxNodes = cmp.Or(instance.settings.MaxNodes, defaultMaxNodes)
+		instance.settings.MaxEdges = cmp.Or(instance.settings.MaxEdges, defaultMaxEdges)
+		instance.settings.LookupMs = cmp.Or(instance.settings.LookupMs, int(defaultLookupDeadline/time.Millisecond))
+		instance.settings.SnapshotDir = cmp.Or(instance.settings.SnapshotDir, snapshotDir())
+		instance.settings.SnapshotMB = cmp.Or(instance.settings.SnapshotMB, defaultSnapshotMB)
+
+		openSnapshots(instance.settings)
+		instance.release = sample(plugin, instance.settings)
 
 		gocore.Error("datasource instance", nil, map[string]string{
 			"id": strconv.Itoa(int(settings.ID)),
 		}).Info()
 
-		return &instance, nil
+		return instance, nil
 	}
 }
 
+// lookupDeadline returns the time a query waits for host name lookups.
+func (settings Settings) lookupDeadline() time.Duration {
+	return time.Duration(settings.LookupMs) * time.Millisecond
+}
+
 // Dispose run when instance cleaned up.
 func (instance *Instance) Dispose() {
 	gocore.Error("Dispose", nil, map[string]string{
 		"datasource": fmt.Sprint(*instance),
 	}).Info()
 
+	if instance.release != nil {
+		instance.release()
+	}
 	*instance = Instance{}
 }
 
@@ -106,7 +143,13 @@ func (instance *Instance) CheckHealth(_ context.Context, req *backend.CheckHealt
 		"message": message,
 	}).Info()
 
-	jsonDetails, _ := json.Marshal(instance)
+	jsonDetails, _ := json.Marshal(struct {
+		*Instance
+		Streams map[string]streamStats `json:"streams"`
+	}{
+		Instance: instance,
+		Streams:  statistics(req.PluginContext),
+	})
 
 	gocore.Error("check health details", errors.New(string(jsonDetails))).Info()
 
@@ -117,17 +160,6 @@ func (instance *Instance) CheckHealth(_ context.Context, req *backend.CheckHealt
 	}, nil
 }
 
-// CallResource of data source.
-func (instance *Instance) CallResource(_ context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	gocore.Error("CallResource", nil, map[string]string{
-		"instance": fmt.Sprint(*instance),
-		"request":  fmt.Sprint(*req),
-		"sender":   fmt.Sprint(sender),
-	}).Info()
-
-	return nil
-}
-
 // QueryData handler for data source.
 func (instance *Instance) QueryData(_ context.Context, req *backend.QueryDataRequest) (resp *backend.QueryDataResponse, err error) {
 	defer func() {
@@ -154,12 +186,56 @@ func (instance *Instance) QueryData(_ context.Context, req *backend.QueryDataReq
 	for _, query := range req.Queries {
 		instance.Query.Queries += 1
 		q := struct {
-			Pid process.Pid `json:"pid"`
+			Pid              process.Pid `json:"pid"`
+			Node             string      `json:"node"`
+			Edge             string      `json:"edge"`
+			Search           string      `json:"search"`
+			MainStat         string      `json:"mainStat"`
+			SecondaryStat    string      `json:"secondaryStat"`
+			From             string      `json:"from"`
+			To               string      `json:"to"`
+			ConnectionWeight *int        `json:"connectionWeight"`
+			ParentWeight     *int        `json:"parentWeight"`
+			Lookup           string      `json:"lookup"`
+			Table            string      `json:"table"`
+			Recent           bool        `json:"recent"`
 		}{}
 		if err = json.Unmarshal(query.JSON, &q); err != nil {
 			resp.Responses[query.RefID] = backend.DataResponse{Error: err}
 			continue
 		}
+		if !validStat(cmp.Or(q.MainStat, statName)) || !validStat(cmp.Or(q.SecondaryStat, statPid)) {
+			resp.Responses[query.RefID] = backend.DataResponse{
+				Error: fmt.Errorf("invalid node stats %q, %q, select from %v", q.MainStat, q.SecondaryStat, statNames),
+			}
+			continue
+		}
+
+		if !validTable(q.Table) {
+			resp.Responses[query.RefID] = backend.DataResponse{
+				Error: fmt.Errorf("unknown table %q", q.Table),
+			}
+			continue
+		}
+
+		connectionWeight, parentWeight := defaultConnectionWeight, defaultParentWeight
+		if q.ConnectionWeight != nil {
+			connectionWeight = *q.ConnectionWeight
+		}
+		if q.ParentWeight != nil {
+			parentWeight = *q.ParentWeight
+		}
+		if err = validPath(q.From, q.To, connectionWeight, parentWeight); err != nil {
+			resp.Responses[query.RefID] = backend.DataResponse{Error: err}
+			continue
+		}
+
+		var start int64
+		if q.Node != "" {
+			if pid, ms, ok := parseProcID(q.Node); ok {
+				q.Pid, start = pid, ms
+			}
+		}
 
 		to := time.Now()
 		from := to.Add(-5 * time.Minute)
@@ -170,15 +246,56 @@ func (instance *Instance) QueryData(_ context.Context, req *backend.QueryDataReq
 			"to":   to.Format("2006-01-02T15:04:05Z07:00"),
 		}).Info()
 
-		link := fmt.Sprintf(
-			`http://localhost:3000/explore?orgId=${__org}&left={"datasource":%q,"range":{"from":%q,"to":%q},"queries":[{"graph":{"label":"processes"},"pid":${__value.raw}}]}`,
-			req.PluginContext.DataSourceInstanceSettings.Name,
-			"now-5m",
-			"now",
-		)
+		link := explore(req.PluginContext, `"node":"${__value.raw}"`)
+		edgeLink := explore(req.PluginContext, `"edge":"${__value.raw}"`)
 
-		resp.Responses[query.RefID] = Nodegraph(link, q.Pid)
+		nq := Query{
+			pid:              q.Pid,
+			start:            start,
+			link:             link,
+			edgeLink:         edgeLink,
+			edge:             q.Edge,
+			search:           q.Search,
+			maxNodes:         instance.settings.MaxNodes,
+			maxEdges:         instance.settings.MaxEdges,
+			offline:          instance.settings.OfflineHosts,
+			deadline:         time.Now().Add(instance.settings.lookupDeadline()),
+			services:         instance.settings.Services,
+			mainStat:         q.MainStat,
+			secondaryStat:    q.SecondaryStat,
+			from:             q.From,
+			to:               q.To,
+			connectionWeight: connectionWeight,
+			parentWeight:     parentWeight,
+			holder:           q.Lookup,
+			table:            q.Table,
+			recent:           q.Recent,
+		}
+
+		// render the stored snapshot for a time range that ended in the past
+		if q.Table == "" && q.Edge == "" && !query.TimeRange.To.IsZero() && time.Since(query.TimeRange.To) > snapshotInterval {
+			gocore.Error("Query snapshot", nil, map[string]string{
+				"pid":  q.Pid.String(),
+				"from": query.TimeRange.From.Format("2006-01-02T15:04:05Z07:00"),
+				"to":   query.TimeRange.To.Format("2006-01-02T15:04:05Z07:00"),
+			}).Info()
+			resp.Responses[query.RefID] = backend.DataResponse{Frames: nq.timeTravel(query.TimeRange.From, query.TimeRange.To)}
+			continue
+		}
+
+		resp.Responses[query.RefID] = Nodegraph(nq)
 	}
 
 	return resp, nil
 }
+
+// explore formats a data link to an explore view of a node graph query with the specified query parameter.
+func explore(pctx backend.PluginContext, param string) string {
+	return fmt.Sprintf(
+		`http://localhost:3000/explore?orgId=${__org}&left={"datasource":%q,"range":{"from":%q,"to":%q},"queries":[{"graph":{"label":"processes"},%s}]}`,
+		pctx.DataSourceInstanceSettings.Name,
+		"now-5m",
+		"now",
+		param,
+	)
+}
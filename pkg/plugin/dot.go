@@ -0,0 +1,84 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var (
+	// dotEscape escapes the characters of a DOT quoted string.
+	dotEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// dotQuote quotes a string as a DOT identifier.
+func dotQuote(s string) string {
+	return `"` + dotEscape.Replace(s) + `"`
+}
+
+// dot formats the node graph in the Graphviz DOT language. Each cluster is a subgraph, and nodes and edges
+// are colored by their category's arc color.
+func (g *graph) dot() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("digraph \"gomon\" {\n")
+	buf.WriteString("\tgraph [rankdir=LR, fontname=\"sans-serif\", fontsize=10, compound=true]\n")
+	buf.WriteString("\tnode [shape=box, style=\"rounded,filled\", fontname=\"sans-serif\", fontsize=9, fontcolor=\"#ffffff\"]\n")
+	buf.WriteString("\tedge [fontname=\"sans-serif\", fontsize=8]\n")
+
+	members := make([][][]any, len(g.clusters))
+	color := map[string]string{}
+	for _, n := range g.nodes {
+		id := n[0].(string)
+		members[g.cluster[id]] = append(members[g.cluster[id]], n)
+		color[id] = classes[g.category(n)].color
+	}
+
+	for i, name := range g.clusters {
+		if len(members[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\tsubgraph %s {\n", dotQuote(fmt.Sprintf("cluster_%d", i)))
+		fmt.Fprintf(&buf, "\t\tlabel=%s\n", dotQuote(name))
+		for _, n := range members[i] {
+			var tooltip []string
+			for _, detail := range nodeDetails {
+				if v, ok := g.details(n)[detail.name]; ok {
+					tooltip = append(tooltip, detail.display+": "+v)
+				}
+			}
+			fmt.Fprintf(&buf, "\t\t%s [label=%s, fillcolor=%s, tooltip=%s]\n",
+				dotQuote(n[0].(string)),
+				dotQuote(n[3].(string)+"\n"+n[1].(string)),
+				dotQuote(color[n[0].(string)]),
+				dotQuote(strings.Join(tooltip, "\n")),
+			)
+		}
+		buf.WriteString("\t}\n")
+	}
+
+	for _, e := range g.edges {
+		var conns []string
+		for _, conn := range e[8:] {
+			if conn.(string) != "" {
+				conns = append(conns, conn.(string))
+			}
+		}
+		var label string // parent/child edges have no connections
+		if n := e[3].(int64); n > 0 {
+			label = fmt.Sprint(n)
+		}
+		fmt.Fprintf(&buf, "\t%s -> %s [label=%s, penwidth=%.1f, color=%s, tooltip=%s]\n",
+			dotQuote(e[1].(string)),
+			dotQuote(e[2].(string)),
+			dotQuote(label),
+			e[5].(float64),
+			dotQuote(color[e[2].(string)]),
+			dotQuote(strings.Join(conns, "\n")),
+		)
+	}
+
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,83 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// endpoint is the parsed form of a connection endpoint's name.
+	endpoint struct {
+		kind string // one of the endpoint kinds
+		host string // ip address, unix socket path or abstract name, or the unparsed name
+		zone string // ipv6 zone
+		port string
+	}
+)
+
+const (
+	// endpoint kinds.
+	endpointInet     = "inet"
+	endpointUnix     = "unix"
+	endpointAbstract = "abstract"
+	endpointUnknown  = "unknown"
+)
+
+// parseEndpoint parses an endpoint name as an ip address with optional zone and port, a unix socket path,
+// or an abstract unix socket name. IPv4-mapped IPv6 addresses are reported as IPv4. A name that does not
+// parse is reported whole as the host of an unknown endpoint.
+func parseEndpoint(name string) endpoint {
+	switch {
+	case name == "":
+		return endpoint{kind: endpointUnknown}
+	case strings.HasPrefix(name, "@"):
+		return endpoint{kind: endpointAbstract, host: name[1:]}
+	case strings.HasPrefix(name, "\x00"):
+		return endpoint{kind: endpointAbstract, host: name[1:]}
+	case filepath.IsAbs(name):
+		return endpoint{kind: endpointUnix, host: name}
+	}
+
+	bare := name
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		bare = name[1 : len(name)-1]
+	}
+	if addr, err := netip.ParseAddr(bare); err == nil && !strings.ContainsAny(addr.Zone(), ":]") { // address without port
+		return inet(addr, "")
+	}
+
+	host, port, err := net.SplitHostPort(name)
+	if err != nil {
+		return endpoint{kind: endpointUnknown, host: name}
+	}
+	if host == "*" || host == "" { // wildcard bind address
+		return endpoint{kind: endpointInet, host: "*", port: port}
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return endpoint{kind: endpointUnknown, host: name}
+	}
+	return inet(addr, port)
+}
+
+// inet creates an ip endpoint, separating any zone from the address.
+func inet(addr netip.Addr, port string) endpoint {
+	return endpoint{
+		kind: endpointInet,
+		host: addr.WithZone("").Unmap().String(),
+		zone: addr.Zone(),
+		port: port,
+	}
+}
+
+// address returns the endpoint's host with any zone, for display.
+func (ep endpoint) address() string {
+	if ep.zone != "" {
+		return ep.host + "%" + ep.zone
+	}
+	return ep.host
+}
@@ -0,0 +1,60 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"testing"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		want endpoint
+	}{
+		{"192.168.1.10:443", endpoint{kind: endpointInet, host: "192.168.1.10", port: "443"}},
+		{"192.168.1.10", endpoint{kind: endpointInet, host: "192.168.1.10"}},
+		{"[2001:db8::1]:8080", endpoint{kind: endpointInet, host: "2001:db8::1", port: "8080"}},
+		{"2001:db8::1", endpoint{kind: endpointInet, host: "2001:db8::1"}},
+		{"[fe80::1%eth0]:22", endpoint{kind: endpointInet, host: "fe80::1", zone: "eth0", port: "22"}},
+		{"fe80::1%en0", endpoint{kind: endpointInet, host: "fe80::1", zone: "en0"}},
+		{"[::ffff:10.0.0.1]:5432", endpoint{kind: endpointInet, host: "10.0.0.1", port: "5432"}},
+		{"[::]:80", endpoint{kind: endpointInet, host: "::", port: "80"}},
+		{"*:53", endpoint{kind: endpointInet, host: "*", port: "53"}},
+		{":53", endpoint{kind: endpointInet, host: "*", port: "53"}},
+		{"/var/run/docker.sock", endpoint{kind: endpointUnix, host: "/var/run/docker.sock"}},
+		{"@/tmp/.X11-unix/X0", endpoint{kind: endpointAbstract, host: "/tmp/.X11-unix/X0"}},
+		{"\x00dbus-session", endpoint{kind: endpointAbstract, host: "dbus-session"}},
+		{"", endpoint{kind: endpointUnknown}},
+		{"12345", endpoint{kind: endpointUnknown, host: "12345"}},
+		{"example.com:443", endpoint{kind: endpointUnknown, host: "example.com:443"}},
+		{"[::1:443", endpoint{kind: endpointUnknown, host: "[::1:443"}},
+		{"1.2.3.4:80:90", endpoint{kind: endpointUnknown, host: "1.2.3.4:80:90"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseEndpoint(tt.name); got != tt.want {
+				t.Errorf("parseEndpoint(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"[fe80::1%eth0]:22", "fe80::1%eth0"},
+		{"10.0.0.1:22", "10.0.0.1"},
+		{"/run/systemd/notify", "/run/systemd/notify"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseEndpoint(tt.name).address(); got != tt.want {
+				t.Errorf("parseEndpoint(%q).address() = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
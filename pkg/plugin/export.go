@@ -0,0 +1,153 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type (
+	// attribute defines a node or edge attribute of an exported graph, with its GraphML type.
+	attribute struct {
+		name string
+		kind string // string, long, or double
+	}
+)
+
+// nodeAttributes lists the attributes of the exported nodes.
+func nodeAttributes() []attribute {
+	attrs := []attribute{
+		{"name", "string"},
+		{"mainStat", "string"},
+		{"secondaryStat", "string"},
+		{"cluster", "string"},
+		{"category", "string"},
+		{"icon", "string"},
+	}
+	for _, detail := range nodeDetails {
+		attrs = append(attrs, attribute{detail.name, "string"})
+	}
+	for _, cls := range classes {
+		attrs = append(attrs, attribute{"arc_" + cls.name, "double"})
+	}
+	return attrs
+}
+
+// edgeAttributes lists the attributes of the exported edges.
+var edgeAttributes = []attribute{
+	{"sourceName", "string"},
+	{"targetName", "string"},
+	{"connections", "long"},
+	{"traffic", "double"},
+	{"thickness", "double"},
+	{"connectionList", "string"},
+}
+
+// nodeValues returns the attribute values of a node.
+func (g *graph) nodeValues(n []any) map[string]any {
+	values := map[string]any{
+		"name":          n[3],
+		"mainStat":      n[1],
+		"secondaryStat": n[2],
+		"cluster":       g.clusters[g.cluster[n[0].(string)]],
+		"category":      classes[g.category(n)].name,
+		"icon":          n[4+len(nodeDetails)],
+	}
+	for name, v := range g.details(n) {
+		values[name] = v
+	}
+	arc := len(n) - len(classes)
+	for i, cls := range classes {
+		values["arc_"+cls.name] = n[arc+i]
+	}
+	return values
+}
+
+// edgeValues returns the attribute values of an edge, including its complete list of connections.
+func (g *graph) edgeValues(e []any) map[string]any {
+	return map[string]any{
+		"sourceName":     e[6],
+		"targetName":     e[7],
+		"connections":    e[3],
+		"traffic":        e[4],
+		"thickness":      e[5],
+		"connectionList": g.conns[e[0].(string)],
+	}
+}
+
+// nodeLink formats the node graph as JSON in the node-link format of NetworkX, also read by Cytoscape.
+func (g *graph) nodeLink() ([]byte, error) {
+	nodes := make([]map[string]any, len(g.nodes))
+	for i, n := range g.nodes {
+		nodes[i] = g.nodeValues(n)
+		nodes[i]["id"] = n[0]
+	}
+	links := make([]map[string]any, len(g.edges))
+	for i, e := range g.edges {
+		links[i] = g.edgeValues(e)
+		links[i]["id"] = e[0]
+		links[i]["source"] = e[1]
+		links[i]["target"] = e[2]
+	}
+	return json.Marshal(map[string]any{
+		"directed":   true,
+		"multigraph": false,
+		"graph": map[string]any{
+			"name":     "gomon",
+			"clusters": g.clusters,
+		},
+		"nodes": nodes,
+		"links": links,
+	})
+}
+
+// graphML formats the node graph as GraphML, read by Gephi, NetworkX, and Cytoscape. GraphML has no list
+// type, so an edge's connections are separated by newlines.
+func (g *graph) graphML() []byte {
+	var buf bytes.Buffer
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">` + "\n")
+	nattrs := nodeAttributes()
+	for _, attr := range nattrs {
+		fmt.Fprintf(&buf, "  <key id=\"n_%s\" for=\"node\" attr.name=\"%[1]s\" attr.type=\"%s\"/>\n", attr.name, attr.kind)
+	}
+	for _, attr := range edgeAttributes {
+		fmt.Fprintf(&buf, "  <key id=\"e_%s\" for=\"edge\" attr.name=\"%[1]s\" attr.type=\"%s\"/>\n", attr.name, attr.kind)
+	}
+	buf.WriteString("  <graph id=\"gomon\" edgedefault=\"directed\">\n")
+
+	for _, n := range g.nodes {
+		fmt.Fprintf(&buf, "    <node id=\"%s\">\n", escape(n[0].(string)))
+		values := g.nodeValues(n)
+		for _, attr := range nattrs {
+			if v, ok := values[attr.name]; ok {
+				fmt.Fprintf(&buf, "      <data key=\"n_%s\">%s</data>\n", attr.name, escape(fmt.Sprint(v)))
+			}
+		}
+		buf.WriteString("    </node>\n")
+	}
+
+	for _, e := range g.edges {
+		fmt.Fprintf(&buf, "    <edge id=\"%s\" source=\"%s\" target=\"%s\">\n",
+			escape(e[0].(string)), escape(e[1].(string)), escape(e[2].(string)))
+		values := g.edgeValues(e)
+		values["connectionList"] = strings.Join(values["connectionList"].([]string), "\n")
+		for _, attr := range edgeAttributes {
+			fmt.Fprintf(&buf, "      <data key=\"e_%s\">%s</data>\n", attr.name, escape(fmt.Sprint(values[attr.name])))
+		}
+		buf.WriteString("    </edge>\n")
+	}
+
+	buf.WriteString("  </graph>\n</graphml>\n")
+	return buf.Bytes()
+}
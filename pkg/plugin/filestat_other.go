@@ -0,0 +1,10 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build !unix
+
+package plugin
+
+// fileStat is not reported on this platform.
+func fileStat(name string) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,25 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build unix
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// fileStat returns the inode and device of a file.
+func fileStat(name string) (string, string) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return "", ""
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	return strconv.FormatUint(uint64(st.Ino), 10), fmt.Sprintf("%d,%d", major(uint64(st.Dev)), minor(uint64(st.Dev)))
+}
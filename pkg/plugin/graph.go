@@ -0,0 +1,106 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"errors"
+	"strings"
+)
+
+type (
+	// graph captures the node graph that BuildGraph builds, for export in other formats.
+	graph struct {
+		clusters []string       // names of the node graph's clusters
+		cluster  map[string]int // index of each node's cluster
+		nodes    [][]any
+		edges    [][]any
+		conns    map[string][]string // all the connections of each edge
+		seen     sightings           // first and last seen times of the nodes and edges
+	}
+)
+
+// capture records the clusters and the (possibly limited) nodes and edges of the node graph, with the
+// complete connection lists of the edges and when the nodes and edges were seen.
+func (g *graph) capture(clusters []nodeCluster, ns, es [][]any, edges map[[2]Pid][]any, seen sightings) {
+	g.clusters = make([]string, len(clusters))
+	g.cluster = map[string]int{}
+	for i, cl := range clusters {
+		g.clusters[i] = cl.name
+		for _, n := range cl.nodes {
+			g.cluster[n[0].(string)] = i
+		}
+	}
+	for i := range clusters {
+		g.cluster[overflowID(i)] = i
+	}
+	g.nodes = ns
+	g.edges = es
+	g.seen = seen
+	g.conns = map[string][]string{}
+	for _, edge := range edges {
+		conns := make([]string, len(edge)-5)
+		for i, conn := range edge[5:] {
+			conns[i] = conn.(string)
+		}
+		g.conns[edge[0].(string)] = conns
+	}
+	for _, e := range es { // overflow edges list their summarized connections
+		if _, ok := g.conns[e[0].(string)]; !ok {
+			var conns []string
+			for _, conn := range e[8 : 8+edgeConnections] {
+				if conn := conn.(string); conn != "" {
+					conns = append(conns, conn)
+				}
+			}
+			g.conns[e[0].(string)] = conns
+		}
+	}
+}
+
+// buildGraph builds the node graph for a query, capturing it for export.
+func buildGraph(query Query) (*graph, error) {
+	g := &graph{}
+	query.graph = g
+	resp := Nodegraph(query)
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if g.cluster == nil { // graph not built, the notices report why
+		var texts []string
+		for _, frame := range resp.Frames {
+			if frame.Meta != nil {
+				for _, notice := range frame.Meta.Notices {
+					texts = append(texts, notice.Text)
+				}
+			}
+		}
+		if len(texts) == 0 {
+			texts = []string{"node graph not built"}
+		}
+		return nil, errors.New(strings.Join(texts, "; "))
+	}
+	return g, nil
+}
+
+// category returns the class of a node's largest arc.
+func (g *graph) category(n []any) class {
+	arc := len(n) - len(classes)
+	cls, top := classProcess, 0.0
+	for i := range classes {
+		if v := n[arc+i].(float64); v > top {
+			cls, top = class(i), v
+		}
+	}
+	return cls
+}
+
+// details returns the non-empty details of a node, by detail name.
+func (g *graph) details(n []any) map[string]string {
+	details := map[string]string{}
+	for i, detail := range nodeDetails {
+		if v := n[4+i].(string); v != "" {
+			details[detail.name] = v
+		}
+	}
+	return details
+}
@@ -0,0 +1,178 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// holding is a connection that matches a reverse lookup, with its process's ancestry.
+	holding struct {
+		conn     process.Connection
+		ancestry []Pid // from the process's parent up to init
+	}
+)
+
+// holds returns the test of whether a connection matches a reverse lookup of a remote host address, CIDR, or
+// name, a local port (e.g. ":5432"), or a file path, which matches the file or the files within a directory.
+func (query Query) holds(spec string) func(process.Connection) bool {
+	if prefix, err := netip.ParsePrefix(spec); err == nil {
+		return func(conn process.Connection) bool {
+			ep := parseEndpoint(conn.Peer.Name)
+			addr, err := netip.ParseAddr(ep.host)
+			return conn.Peer.Pid < 0 && ep.kind == endpointInet && err == nil && prefix.Contains(addr)
+		}
+	}
+	if addr, err := netip.ParseAddr(spec); err == nil {
+		return func(conn process.Connection) bool {
+			ep := parseEndpoint(conn.Peer.Name)
+			return conn.Peer.Pid < 0 && ep.kind == endpointInet && ep.host == addr.Unmap().String()
+		}
+	}
+	if port := strings.TrimPrefix(spec, ":"); isPort(port) {
+		return func(conn process.Connection) bool {
+			self := parseEndpoint(conn.Self.Name)
+			if self.kind == endpointInet {
+				return self.port == port
+			}
+			return listening(conn) && parseEndpoint(conn.Peer.Name).port == port // bind address of listen socket
+		}
+	}
+	if filepath.IsAbs(spec) {
+		dir := strings.TrimSuffix(spec, "/") + "/"
+		return func(conn process.Connection) bool {
+			for _, name := range []string{conn.Peer.Name, conn.Self.Name} {
+				if name == spec || strings.HasPrefix(name, dir) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return func(conn process.Connection) bool { // host name
+		ep := parseEndpoint(conn.Peer.Name)
+		return conn.Peer.Pid < 0 && ep.kind == endpointInet && ep.host != "*" && query.hostname(ep.host) == spec
+	}
+}
+
+// isPort reports whether a string is a port number, digits only.
+func isPort(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 16)
+	return err == nil
+}
+
+// ancestry lists the ancestors of a process, from its parent up to init.
+func ancestry(tb process.Table, pid Pid) []Pid {
+	var pids []Pid
+	seen := map[Pid]bool{pid: true}
+	for p := tb[pid]; p != nil && p.HasParent() && !seen[p.Ppid]; p = tb[p.Ppid] {
+		if _, ok := tb[p.Ppid]; !ok {
+			break
+		}
+		seen[p.Ppid] = true
+		pids = append(pids, p.Ppid)
+	}
+	return pids
+}
+
+// holders reduces the node graph to the processes holding connections that match the query's reverse lookup,
+// their ancestors, and the matched connections' peers. A table lists each process's matched connections.
+func (query Query) holders(tb process.Table, ids map[Pid]string, clusters []nodeCluster, es [][]any) ([]nodeCluster, [][]any, *data.Frame) {
+	holds := query.holds(query.holder)
+	var holdings []holding
+	kept := map[string]bool{}
+	for pid, p := range tb {
+		for _, conn := range p.Connections {
+			if conn.Self.Pid != pid || !holds(conn) {
+				continue
+			}
+			h := holding{conn: conn, ancestry: ancestry(tb, pid)}
+			holdings = append(holdings, h)
+			kept[ids[pid]] = true
+			kept[ids[conn.Peer.Pid]] = true
+			for _, ppid := range h.ancestry {
+				kept[ids[ppid]] = true
+			}
+		}
+	}
+	delete(kept, "")
+
+	var held []nodeCluster
+	for _, cl := range clusters {
+		var nodes [][]any
+		for _, n := range cl.nodes {
+			if kept[n[0].(string)] {
+				nodes = append(nodes, n)
+			}
+		}
+		held = append(held, nodeCluster{cl.name, nodes})
+	}
+	var edges [][]any
+	for _, e := range es {
+		if kept[e[1].(string)] && kept[e[2].(string)] {
+			edges = append(edges, e)
+		}
+	}
+
+	return held, edges, holdersFrame(query.holder, tb, holdings)
+}
+
+// holdersFrame tabulates the processes holding the connections that match a reverse lookup.
+func holdersFrame(spec string, tb process.Table, holdings []holding) *data.Frame {
+	slices.SortFunc(holdings, func(a, b holding) int {
+		return cmp.Or(
+			cmp.Compare(a.conn.Self.Pid, b.conn.Self.Pid),
+			cmp.Compare(a.conn.Peer.Name, b.conn.Peer.Name),
+		)
+	})
+
+	frame := data.NewFrame("holders",
+		data.NewField("pid", nil, []int64{}),
+		data.NewField("executable", nil, []string{}),
+		data.NewField("user", nil, []string{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("self", nil, []string{}),
+		data.NewField("peer", nil, []string{}),
+		data.NewField("ancestry", nil, []string{}),
+	)
+	for _, h := range holdings {
+		p := tb[h.conn.Self.Pid]
+		chain := make([]string, len(h.ancestry))
+		for i, pid := range h.ancestry {
+			chain[i] = tb[pid].Shortname()
+		}
+		frame.AppendRow(
+			int64(p.Pid),
+			p.Executable,
+			p.Username,
+			h.conn.Type,
+			h.conn.Self.Name,
+			h.conn.Peer.Name,
+			strings.Join(chain, " < "),
+		)
+	}
+	for i, display := range []string{"PID", "Executable", "User", "Type", "Self", "Peer", "Ancestry"} {
+		frame.Fields[i].Config = &data.FieldConfig{DisplayName: display}
+	}
+	frame.SetMeta(&data.FrameMeta{
+		Path:                   "holders",
+		PreferredVisualization: data.VisTypeTable,
+	})
+	if len(holdings) == 0 {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("no process holds a connection matching %q", spec),
+		})
+	}
+	return frame
+}
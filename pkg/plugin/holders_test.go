@@ -0,0 +1,57 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/zosmac/gomon/process"
+)
+
+func TestHolds(t *testing.T) {
+	var (
+		ipv4    = process.Connection{Type: "TCP", Self: process.Endpoint{Name: "10.0.0.1:40000", Pid: 10}, Peer: process.Endpoint{Name: "10.0.0.2:443", Pid: -1}}
+		ipv6    = process.Connection{Type: "TCP6", Self: process.Endpoint{Name: "[::1]:40001", Pid: 10}, Peer: process.Endpoint{Name: "[::1]:5432", Pid: -2}}
+		global6 = process.Connection{Type: "TCP6", Self: process.Endpoint{Name: "[2001:db8::1]:40002", Pid: 10}, Peer: process.Endpoint{Name: "[2001:db8::2]:443", Pid: -3}}
+		listen  = process.Connection{Type: "TCP", Self: process.Endpoint{Name: "0.0.0.0:5432", Pid: 11}, Peer: process.Endpoint{Name: "0.0.0.0:0", Pid: -4}}
+		file    = process.Connection{Type: "REG", Self: process.Endpoint{Name: "3", Pid: 10}, Peer: process.Endpoint{Name: "/var/log/syslog", Pid: 1 << 31}}
+	)
+
+	tests := []struct {
+		spec string
+		conn process.Connection
+		want bool
+	}{
+		{"10.0.0.2", ipv4, true},
+		{"10.0.0.3", ipv4, false},
+		{"10.0.0.0/24", ipv4, true},
+		{"10.1.0.0/16", ipv4, false},
+		{"::1", ipv6, true},
+		{"::1", ipv4, false},
+		{"::", ipv6, false},
+		{"::/0", ipv6, true},
+		{"::/0", global6, true},
+		{"::/0", ipv4, false},
+		{"2001:db8::/32", global6, true},
+		{"2001:db8::/32", ipv6, false},
+		{":5432", listen, true},
+		{"5432", listen, true},
+		{":443", ipv4, false},
+		{":40000", ipv4, true},
+		{":5432x", listen, false},
+		{":", listen, false},
+		{"/var/log/syslog", file, true},
+		{"/var/log", file, true},
+		{"/var/lo", file, false},
+		{"example.com", ipv4, false},
+	}
+
+	query := Query{offline: true}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if got := query.holds(tt.spec)(tt.conn); got != tt.want {
+				t.Errorf("holds(%q)(%s -> %s) = %t, want %t", tt.spec, tt.conn.Self.Name, tt.conn.Peer.Name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// listener is a listening TCP socket or bound UDP socket.
+	listener struct {
+		bind     endpoint
+		protocol string
+		pid      Pid
+	}
+)
+
+const (
+	// tableListening selects the table query of the listening ports inventory.
+	tableListening = "listening"
+)
+
+// validTable reports whether a table query is known.
+func validTable(table string) bool {
+	return table == "" || table == tableListening || table == tableShortLived
+}
+
+// bound returns the bound address of a listening TCP or UDP socket, the end of the connection named ip:port.
+func bound(conn process.Connection) (endpoint, bool) {
+	if t := baseType(conn.Type); t != "TCP" && t != "UDP" || !listening(conn) {
+		return endpoint{}, false
+	}
+	for _, name := range []string{conn.Self.Name, conn.Peer.Name} {
+		if ep := parseEndpoint(name); ep.kind == endpointInet && ep.port != "" && ep.port != "0" {
+			return ep, true
+		}
+	}
+	return endpoint{}, false
+}
+
+// exposed reports whether a socket is bound to all interfaces.
+func (ep endpoint) exposed() bool {
+	return ep.host == "*" || ep.host == "0.0.0.0" || ep.host == "::"
+}
+
+// listeningFrame tabulates the listening TCP and bound UDP sockets, flagging those exposed on all interfaces.
+func listeningFrame(tb process.Table) *data.Frame {
+	var ls []listener
+	for pid, p := range tb {
+		for _, conn := range p.Connections {
+			if bind, ok := bound(conn); ok && conn.Self.Pid == pid {
+				ls = append(ls, listener{bind, conn.Type, pid})
+			}
+		}
+	}
+	slices.SortFunc(ls, func(a, b listener) int {
+		pa, _ := strconv.Atoi(a.bind.port)
+		pb, _ := strconv.Atoi(b.bind.port)
+		return cmp.Or(
+			cmp.Compare(pa, pb),
+			cmp.Compare(a.protocol, b.protocol),
+			cmp.Compare(a.bind.address(), b.bind.address()),
+			cmp.Compare(a.pid, b.pid),
+		)
+	})
+	ls = slices.Compact(ls)
+
+	frame := data.NewFrame("listening",
+		data.NewField("address", nil, []string{}),
+		data.NewField("port", nil, []int64{}),
+		data.NewField("protocol", nil, []string{}),
+		data.NewField("pid", nil, []int64{}),
+		data.NewField("executable", nil, []string{}),
+		data.NewField("user", nil, []string{}),
+		data.NewField("exposed", nil, []bool{}),
+	)
+	for _, l := range ls {
+		port, _ := strconv.ParseInt(l.bind.port, 10, 64)
+		p := tb[l.pid]
+		frame.AppendRow(
+			l.bind.address(),
+			port,
+			l.protocol,
+			int64(l.pid),
+			p.Executable,
+			p.Username,
+			l.bind.exposed(),
+		)
+	}
+	for i, display := range []string{"Bind Address", "Port", "Protocol", "PID", "Executable", "User", "All Interfaces"} {
+		frame.Fields[i].Config = &data.FieldConfig{DisplayName: display}
+	}
+	frame.SetMeta(&data.FrameMeta{
+		Path:                   tableListening,
+		PreferredVisualization: data.VisTypeTable,
+	})
+	return frame
+}
@@ -0,0 +1,52 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/zosmac/gomon/process"
+)
+
+func TestBound(t *testing.T) {
+	tests := []struct {
+		name string
+		conn process.Connection
+		want string
+		ok   bool
+	}{
+		{"tcp listener", process.Connection{Type: "TCP", Self: process.Endpoint{Name: "0.0.0.0:22"}, Peer: process.Endpoint{Name: "0.0.0.0:0"}}, "0.0.0.0:22", true},
+		{"tcp6 listener", process.Connection{Type: "TCP6", Self: process.Endpoint{Name: "[::]:5432"}, Peer: process.Endpoint{Name: "[::]:0"}}, ":::5432", true},
+		{"udp6 socket", process.Connection{Type: "UDP6", Self: process.Endpoint{Name: "[::1]:53"}, Peer: process.Endpoint{Name: "[::]:0"}}, "::1:53", true},
+		{"tcp6 established", process.Connection{Type: "TCP6", Self: process.Endpoint{Name: "[::1]:5432"}, Peer: process.Endpoint{Name: "[::1]:40000"}}, "", false},
+		{"unix socket", process.Connection{Type: "unix", Self: process.Endpoint{Name: "/run/docker.sock"}, Peer: process.Endpoint{Name: ""}}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep, ok := bound(tt.conn)
+			if got := ep.address() + ":" + ep.port; ok != tt.ok || ok && got != tt.want {
+				t.Errorf("bound(%+v) = %q, %t, want %q, %t", tt.conn, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestListeningFrameIPv6(t *testing.T) {
+	p := &process.Process{
+		Connections: []process.Connection{{
+			Type: "TCP6",
+			Self: process.Endpoint{Name: "[::]:5432", Pid: 100},
+			Peer: process.Endpoint{Name: "[::]:0"},
+		}},
+	}
+	p.Executable = "/usr/bin/postgres"
+
+	frame := listeningFrame(process.Table{100: p})
+	if n, _ := frame.RowLen(); n != 1 {
+		t.Fatalf("listeningFrame() has %d rows, want 1", n)
+	}
+	if address, port, exposed := frame.At(0, 0), frame.At(1, 0), frame.At(6, 0); address != "::" || port != int64(5432) || exposed != true {
+		t.Errorf("listeningFrame() row = %v %v %v, want :: 5432 true", address, port, exposed)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/zosmac/gocore"
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// lifetime records when a connection of a process was first and last seen.
+	lifetime struct {
+		conn       process.Connection
+		proc       string // node id of the process, pid@start, to detect pid reuse
+		executable string
+		firstSeen  time.Time
+		lastSeen   time.Time
+	}
+
+	// lifetimeKey identifies a connection by its type, owning process, and endpoints.
+	lifetimeKey struct {
+		kind string
+		pid  Pid
+		self string
+		peer string
+	}
+)
+
+const (
+	// tableShortLived selects the table query of the connections that closed soon after opening.
+	tableShortLived = "shortLived"
+
+	// lifetimeRetention is how long a connection is remembered after it was last seen.
+	lifetimeRetention = time.Hour
+
+	// maxLifetimes caps the number of connections remembered.
+	maxLifetimes = 100000
+
+	// lifetimeSample is the interval at which the sampler records the connections. Connections that open
+	// and close between samples are not detected.
+	lifetimeSample = 5 * time.Second
+
+	// closedSuffix marks the closed connections in an edge's connection list.
+	closedSuffix = " (closed)"
+
+	// shortLived is the longest that a connection may have been open to be reported as short lived.
+	shortLived = time.Minute
+)
+
+var (
+	// lifetimes is the rolling record of the processes' socket connections.
+	lifetimes = struct {
+		sync.Mutex
+		sampled  time.Time // time of the most recent sample
+		records  map[lifetimeKey]*lifetime
+		settings Settings           // settings of the most recent instance, for the snapshots
+		users    int                // instances using the sampler
+		cancel   context.CancelFunc // stops the sampler
+	}{
+		records: map[lifetimeKey]*lifetime{},
+	}
+)
+
+// track records the socket connections of the processes as seen at a time.
+func track(tb process.Table, now time.Time) {
+	lifetimes.Lock()
+	defer lifetimes.Unlock()
+
+	for pid, p := range tb {
+		for _, conn := range p.Connections {
+			if conn.Self.Pid != pid || conn.Peer.Pid >= math.MaxInt32 || listening(conn) {
+				continue // only connected sockets, not data
+			}
+			if _, ok := socketKey(conn.Self.Name, conn.Peer.Name); !ok {
+				continue
+			}
+			key := lifetimeKey{conn.Type, pid, conn.Self.Name, conn.Peer.Name}
+			if lt, ok := lifetimes.records[key]; ok {
+				lt.lastSeen = now
+				continue
+			}
+			lifetimes.records[key] = &lifetime{
+				conn:       conn,
+				proc:       procID(p),
+				executable: p.Executable,
+				firstSeen:  now,
+				lastSeen:   now,
+			}
+		}
+	}
+	lifetimes.sampled = now
+
+	for key, lt := range lifetimes.records {
+		if now.Sub(lt.lastSeen) > lifetimeRetention {
+			delete(lifetimes.records, key)
+		}
+	}
+	if n := len(lifetimes.records) - maxLifetimes; n > 0 {
+		keys := slices.Collect(maps.Keys(lifetimes.records))
+		slices.SortFunc(keys, func(a, b lifetimeKey) int {
+			return lifetimes.records[a].lastSeen.Compare(lifetimes.records[b].lastSeen)
+		})
+		for _, key := range keys[:n] {
+			delete(lifetimes.records, key)
+		}
+	}
+}
+
+// recorded returns copies of the connection lifetimes, and the time of the most recent sample.
+func recorded() ([]lifetime, time.Time) {
+	lifetimes.Lock()
+	defer lifetimes.Unlock()
+	lts := make([]lifetime, 0, len(lifetimes.records))
+	for _, lt := range lifetimes.records {
+		lts = append(lts, *lt)
+	}
+	return lts, lifetimes.sampled
+}
+
+// sample registers a datasource instance with the plugin-wide sampler that records the connections between queries
+// and periodically stores a snapshot of the node graph, starting the sampler for the first instance. The sampler
+// runs until the context is cancelled or every instance releases it with the returned function.
+func sample(ctx context.Context, settings Settings) (release func()) {
+	lifetimes.Lock()
+	defer lifetimes.Unlock()
+	lifetimes.settings = settings
+	lifetimes.users += 1
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			lifetimes.Lock()
+			defer lifetimes.Unlock()
+			if lifetimes.users -= 1; lifetimes.users == 0 {
+				lifetimes.cancel()
+				lifetimes.cancel = nil
+			}
+		})
+	}
+	if lifetimes.users > 1 {
+		return release
+	}
+	ctx, lifetimes.cancel = context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(lifetimeSample)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							gocore.Error("sample panic", fmt.Errorf("%v", r)).Err()
+						}
+					}()
+					if !snapshotDue(now) {
+						track(process.BuildTable(), now)
+						return
+					}
+					// build the whole node graph, which records the connections, to store a snapshot
+					lifetimes.Lock()
+					settings := lifetimes.settings
+					lifetimes.Unlock()
+					g, err := buildGraph(Query{
+						maxNodes: settings.MaxNodes,
+						maxEdges: settings.MaxEdges,
+						offline:  settings.OfflineHosts,
+						services: settings.Services,
+					})
+					if err == nil {
+						err = saveSnapshot(now, g)
+					}
+					if err != nil {
+						gocore.Error("saveSnapshot", err).Err()
+					}
+				}()
+			}
+		}
+	}()
+	return release
+}
+
+// closed adds to the node graph the socket connections of the existing processes seen in the last hour that
+// have since closed. Closed connections are marked in the edges' connection lists.
+func (query Query) closed(tb process.Table, hosts map[Pid][]any, edges map[[2]Pid][]any) {
+	lts, sampled := recorded()
+
+	// synthetic pids of the host nodes, by node id
+	hostPids := map[string]Pid{}
+	next := Pid(-1)
+	for pid, n := range hosts {
+		hostPids[n[0].(string)] = pid
+		next = min(next, pid-1)
+	}
+
+	for _, lt := range lts {
+		self := lt.conn.Self.Pid
+		if !lt.lastSeen.Before(sampled) {
+			continue // still open
+		}
+		if p, ok := tb[self]; !ok || procID(p) != lt.proc {
+			continue // process has exited, its pid may have been reused
+		}
+		conn := lt.conn
+		var id [2]Pid
+		if conn.Peer.Pid < 0 {
+			n := query.HostNode(conn)
+			pid, ok := hostPids[n[0].(string)]
+			if !ok {
+				pid, next = next, next-1
+				hostPids[n[0].(string)] = pid
+				hosts[pid] = n
+			}
+			conn.Peer.Pid = pid
+			id = [2]Pid{pid, self}
+			closed := conn.Peer.Name + query.Arrow() + conn.Self.Name + closedSuffix
+			if e, ok := edges[id]; ok {
+				edges[id] = append(e, closed)
+			} else {
+				edges[id] = append(query.HostEdge(tb, conn), closed)
+			}
+		} else if _, ok := tb[conn.Peer.Pid]; ok && self < conn.Peer.Pid {
+			id = [2]Pid{self, conn.Peer.Pid}
+			closed := conn.Self.Name + query.Arrow() + conn.Peer.Name + closedSuffix
+			if e, ok := edges[id]; ok {
+				edges[id] = append(e, closed)
+			} else {
+				edges[id] = append(query.ProcEdge(tb, self, conn.Peer.Pid), closed)
+			}
+		}
+	}
+}
+
+// shortLivedFrame tabulates the connections seen in the last hour that closed within a minute of opening.
+func shortLivedFrame() *data.Frame {
+	lts, sampled := recorded()
+	lts = slices.DeleteFunc(lts, func(lt lifetime) bool {
+		return !lt.lastSeen.Before(sampled) || lt.lastSeen.Sub(lt.firstSeen) > shortLived
+	})
+	slices.SortFunc(lts, func(a, b lifetime) int {
+		return cmp.Or(
+			b.lastSeen.Compare(a.lastSeen),
+			cmp.Compare(a.conn.Self.Pid, b.conn.Self.Pid),
+		)
+	})
+
+	frame := data.NewFrame(tableShortLived,
+		data.NewField("firstSeen", nil, []time.Time{}),
+		data.NewField("lastSeen", nil, []time.Time{}),
+		data.NewField("duration", nil, []float64{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("self", nil, []string{}),
+		data.NewField("peer", nil, []string{}),
+		data.NewField("pid", nil, []int64{}),
+		data.NewField("executable", nil, []string{}),
+	)
+	for _, lt := range lts {
+		frame.AppendRow(
+			lt.firstSeen,
+			lt.lastSeen,
+			lt.lastSeen.Sub(lt.firstSeen).Seconds(),
+			lt.conn.Type,
+			lt.conn.Self.Name,
+			lt.conn.Peer.Name,
+			int64(lt.conn.Self.Pid),
+			lt.executable,
+		)
+	}
+	for i, display := range []string{"First Seen", "Last Seen", "Duration", "Type", "Self", "Peer", "PID", "Executable"} {
+		frame.Fields[i].Config = &data.FieldConfig{DisplayName: display}
+	}
+	frame.Fields[2].Config.Unit = "s"
+	frame.SetMeta(&data.FrameMeta{
+		Path:                   tableShortLived,
+		PreferredVisualization: data.VisTypeTable,
+	})
+	return frame
+}
@@ -0,0 +1,207 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type (
+	// nodeCluster names a cluster of the node graph and lists its nodes.
+	nodeCluster struct {
+		name  string
+		nodes [][]any
+	}
+)
+
+const (
+	// defaultMaxNodes and defaultMaxEdges cap the size of the node graph if not configured in the datasource settings.
+	defaultMaxNodes = 500
+	defaultMaxEdges = 1000
+)
+
+// limit caps the nodes and edges of the node graph. Nodes are ranked by whether they neighbor the queried pid,
+// then by their degree, then by id. The nodes dropped from each cluster are summarized in an overflow node whose
+// arcs average those of the dropped nodes, with slots reserved for the overflow nodes within the cap. The edges
+// of dropped nodes are redirected to their overflow nodes. Edges are ranked by whether they connect the queried
+// pid, then by their number of connections. Any truncation is reported in notices.
+func (query Query) limit(focus string, clusters []nodeCluster, es [][]any) ([][]any, [][]any, []data.Notice) {
+	var total int
+	for _, cl := range clusters {
+		total += len(cl.nodes)
+	}
+
+	degree := map[string]int{}
+	neighbor := map[string]bool{focus: focus != ""}
+	for _, e := range es {
+		source, target := e[1].(string), e[2].(string)
+		degree[source] += 1
+		degree[target] += 1
+		if focus != "" && (source == focus || target == focus) {
+			neighbor[source] = true
+			neighbor[target] = true
+		}
+	}
+
+	kept := map[string]bool{}
+	truncated := query.maxNodes > 0 && total > query.maxNodes
+	if !truncated {
+		for _, cl := range clusters {
+			for _, n := range cl.nodes {
+				kept[n[0].(string)] = true
+			}
+		}
+	} else {
+		var ids []string
+		for _, cl := range clusters {
+			for _, n := range cl.nodes {
+				ids = append(ids, n[0].(string))
+			}
+		}
+		slices.SortFunc(ids, func(a, b string) int {
+			if neighbor[a] != neighbor[b] {
+				if neighbor[a] {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Or(
+				cmp.Compare(degree[b], degree[a]),
+				cmp.Compare(a, b),
+			)
+		})
+		// reserve a slot for the overflow node of each cluster with dropped nodes
+		for slots := query.maxNodes; ; {
+			clear(kept)
+			for _, id := range ids[:slots] {
+				kept[id] = true
+			}
+			var overflows int
+			for _, cl := range clusters {
+				if slices.ContainsFunc(cl.nodes, func(n []any) bool { return !kept[n[0].(string)] }) {
+					overflows++
+				}
+			}
+			if slots+overflows <= query.maxNodes || slots == 0 {
+				break
+			}
+			slots = max(query.maxNodes-overflows, 0)
+		}
+	}
+
+	var ns [][]any
+	summarized := map[string]string{}
+	for i, cl := range clusters {
+		var dropped [][]any
+		for _, n := range cl.nodes {
+			if kept[n[0].(string)] {
+				ns = append(ns, n)
+			} else {
+				dropped = append(dropped, n)
+				summarized[n[0].(string)] = overflowID(i)
+			}
+		}
+		if len(dropped) > 0 {
+			ns = append(ns, overflow(overflowID(i), cl.name, dropped))
+		}
+	}
+
+	// redirect the edges of dropped nodes to their overflow nodes, merging those that coincide
+	var candidates [][]any
+	var order [][2]string
+	merged := map[[2]string][]any{}
+	for _, e := range es {
+		source, target := e[1].(string), e[2].(string)
+		if !truncated || kept[source] && kept[target] {
+			candidates = append(candidates, e)
+			continue
+		}
+		id := [2]string{cmp.Or(summarized[source], source), cmp.Or(summarized[target], target)}
+		if id[0] == id[1] {
+			continue
+		}
+		m, ok := merged[id]
+		if !ok {
+			m = []any{"overflow:" + id[0] + ">" + id[1], id[0], id[1], int64(0), 0.0, 0.0, "", ""}
+			order = append(order, id)
+		}
+		m[3] = m[3].(int64) + e[3].(int64)
+		m[4] = m[4].(float64) + e[4].(float64)
+		for _, conn := range e[8 : 8+edgeConnections] {
+			if conn != "" {
+				m = append(m, conn)
+			}
+		}
+		merged[id] = m
+	}
+	for _, id := range order {
+		m := merged[id]
+		m[5] = thickness(int(m[3].(int64)), m[4].(float64))
+		candidates = append(candidates, append(m[:8:8], summary(m[8:])...))
+	}
+	if query.maxEdges > 0 && len(candidates) > query.maxEdges {
+		ranked := slices.Clone(candidates)
+		slices.SortStableFunc(ranked, func(a, b []any) int {
+			na := neighbor[a[1].(string)] && neighbor[a[2].(string)]
+			nb := neighbor[b[1].(string)] && neighbor[b[2].(string)]
+			if na != nb {
+				if na {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Compare(b[3].(int64), a[3].(int64))
+		})
+		keep := map[string]bool{}
+		for _, e := range ranked[:query.maxEdges] {
+			keep[e[0].(string)] = true
+		}
+		candidates = slices.DeleteFunc(candidates, func(e []any) bool {
+			return !keep[e[0].(string)]
+		})
+	}
+
+	var edges int
+	for _, e := range candidates {
+		if !strings.HasPrefix(e[0].(string), "overflow:") {
+			edges++
+		}
+	}
+	var notices []data.Notice
+	if len(kept) < total || edges < len(es) {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text: fmt.Sprintf("node graph truncated to %d of %d nodes and %d of %d edges",
+				len(kept), total, edges, len(es),
+			),
+		})
+	}
+
+	return ns, candidates, notices
+}
+
+// overflowID returns the id of the overflow node of a cluster.
+func overflowID(i int) string {
+	return "overflow:" + strconv.Itoa(i)
+}
+
+// overflow creates the node that summarizes the nodes dropped from a cluster.
+func overflow(id string, name string, dropped [][]any) []any {
+	n := node(id, fmt.Sprintf("%d more", len(dropped)), name, fmt.Sprintf("%d more %s", len(dropped), name), nil, classKernel)
+	arc := len(n) - len(classes)
+	n[arc-1] = "ellipsis-h"
+	for i := range classes {
+		var sum float64
+		for _, d := range dropped {
+			sum += d[len(d)-len(classes)+i].(float64)
+		}
+		n[arc+i] = sum / float64(len(dropped))
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+var (
+	// mermaidEscape escapes the characters of a Mermaid quoted label as entity codes.
+	mermaidEscape = strings.NewReplacer(`"`, "#quot;", "\n", "<br>", "<", "#lt;", ">", "#gt;")
+)
+
+// mermaid formats the node graph as a Mermaid flowchart for markdown documents. Each cluster is a subgraph.
+// Nodes are styled by their category's arc color, and parent/child edges are drawn dotted.
+func (g *graph) mermaid() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("flowchart LR\n")
+
+	// Mermaid ids must be simple identifiers
+	ids := map[string]string{}
+	members := make([][][]any, len(g.clusters))
+	used := map[class]bool{}
+	for i, n := range g.nodes {
+		id := n[0].(string)
+		ids[id] = fmt.Sprintf("n%d", i)
+		members[g.cluster[id]] = append(members[g.cluster[id]], n)
+		used[g.category(n)] = true
+	}
+
+	for i, cls := range classes {
+		if used[class(i)] {
+			fmt.Fprintf(&buf, "    classDef %s fill:%s,color:#ffffff\n", cls.name, cls.color)
+		}
+	}
+
+	for i, name := range g.clusters {
+		if len(members[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "    subgraph c%d [\"%s\"]\n", i, mermaidEscape.Replace(name))
+		for _, n := range members[i] {
+			fmt.Fprintf(&buf, "        %s[\"%s\"]:::%s\n",
+				ids[n[0].(string)],
+				mermaidEscape.Replace(n[3].(string)+"\n"+n[1].(string)),
+				classes[g.category(n)].name,
+			)
+		}
+		buf.WriteString("    end\n")
+	}
+
+	for _, e := range g.edges {
+		source, target := ids[e[1].(string)], ids[e[2].(string)]
+		if parental(e) {
+			fmt.Fprintf(&buf, "    %s -.-> %s\n", source, target) // parent/child
+		} else {
+			fmt.Fprintf(&buf, "    %s -->|%d| %s\n", source, e[3].(int64), target)
+		}
+	}
+
+	return buf.Bytes()
+}
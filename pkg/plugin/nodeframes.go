@@ -10,33 +10,46 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
-func nodeFrames(link string, ns, es [][]any, maxConnections int) []*data.Frame {
+func nodeFrames(query Query, ns, es [][]any) []*data.Frame {
 	timestamp := time.Now()
+	link := query.link
 
-	nodes := data.NewFrameOfFieldTypes("nodes", len(ns),
+	sighted := func(id string) (*time.Time, *time.Time) {
+		if t, ok := query.seen[id]; ok {
+			return &t[0], &t[1]
+		}
+		return nil, nil // not recorded
+	}
+
+	flds := []data.FieldType{
 		data.FieldTypeTime,
-		data.FieldTypeInt64,
 		data.FieldTypeString,
 		data.FieldTypeString,
 		data.FieldTypeString,
-		data.FieldTypeFloat64,
-		data.FieldTypeFloat64,
-		data.FieldTypeFloat64,
-		data.FieldTypeFloat64,
-		data.FieldTypeFloat64,
-	)
-	nodes.SetFieldNames(
+		data.FieldTypeString,
+	}
+	names := []string{
 		"time",
 		"id",
 		"mainStat",
 		"secondaryStat",
 		"detail__name",
-		"arc__host",
-		"arc__process",
-		"arc__data",
-		"arc__socket",
-		"arc__kernel",
-	)
+	}
+	for _, detail := range nodeDetails {
+		flds = append(flds, data.FieldTypeString)
+		names = append(names, "detail__"+detail.name)
+	}
+	flds = append(flds, data.FieldTypeString)
+	names = append(names, "icon")
+	for _, cls := range classes {
+		flds = append(flds, data.FieldTypeFloat64)
+		names = append(names, "arc__"+cls.name)
+	}
+	flds = append(flds, data.FieldTypeBool, data.FieldTypeNullableTime, data.FieldTypeNullableTime)
+	names = append(names, "highlighted", "firstSeen", "lastSeen")
+
+	nodes := data.NewFrameOfFieldTypes("nodes", len(ns), flds...)
+	nodes.SetFieldNames(names...)
 	nodes.SetMeta(&data.FrameMeta{
 		Path:                   "node",
 		PreferredVisualization: data.VisType("nodeGraph"),
@@ -72,56 +85,74 @@ func nodeFrames(link string, ns, es [][]any, maxConnections int) []*data.Frame {
 		DisplayName: "Name",
 		Path:        "name",
 	}
-	nodes.Fields[5].Config = &data.FieldConfig{
-		Color:       red,
-		DisplayName: "Host",
-		Path:        "host",
+	for i, detail := range nodeDetails {
+		nodes.Fields[i+5].Config = &data.FieldConfig{
+			DisplayName: detail.display,
+			Path:        detail.name,
+		}
+	}
+	arc := 5 + len(nodeDetails)
+	nodes.Fields[arc].Config = &data.FieldConfig{
+		DisplayName: "Icon",
+		Path:        "icon",
 	}
-	nodes.Fields[6].Config = &data.FieldConfig{
-		Color:       blue,
-		DisplayName: "Process",
-		Path:        "process",
+	arc += 1
+	for i, cls := range classes {
+		nodes.Fields[arc+i].Config = &data.FieldConfig{
+			Color:       map[string]any{"mode": "fixed", "fixedColor": cls.color},
+			DisplayName: cls.display,
+			Path:        cls.name,
+		}
 	}
-	nodes.Fields[7].Config = &data.FieldConfig{
-		Color:       yellow,
-		DisplayName: "Data",
-		Path:        "data",
+	nodes.Fields[arc+len(classes)].Config = &data.FieldConfig{
+		DisplayName: "Highlighted",
+		Path:        "highlighted",
 	}
-	nodes.Fields[8].Config = &data.FieldConfig{
-		Color:       magenta,
-		DisplayName: "Socket",
-		Path:        "socket",
+	nodes.Fields[arc+len(classes)+1].Config = &data.FieldConfig{
+		DisplayName: "First Seen",
+		Path:        "firstSeen",
 	}
-	nodes.Fields[9].Config = &data.FieldConfig{
-		Color:       cyan,
-		DisplayName: "Kernel",
-		Path:        "kernel",
+	nodes.Fields[arc+len(classes)+2].Config = &data.FieldConfig{
+		DisplayName: "Last Seen",
+		Path:        "lastSeen",
 	}
 
+	// highlight the nodes that match the search term
+	matched := map[string]bool{}
 	for i, n := range ns {
-		nodes.SetRow(i, append([]any{timestamp}, n...)...)
+		matched[n[0].(string)] = query.matches(n)
+		first, last := sighted(n[0].(string))
+		nodes.SetRow(i, append(append([]any{timestamp}, n...), matched[n[0].(string)], first, last)...)
 	}
 
-	flds := []data.FieldType{
+	flds = []data.FieldType{
 		data.FieldTypeTime,
 		data.FieldTypeString,
+		data.FieldTypeString,
+		data.FieldTypeString,
 		data.FieldTypeInt64,
-		data.FieldTypeInt64,
+		data.FieldTypeFloat64,
+		data.FieldTypeFloat64,
 		data.FieldTypeString,
 		data.FieldTypeString,
 	}
-	names := []string{
+	names = []string{
 		"time",
 		"id",
 		"source",
 		"target",
 		"mainStat",
 		"secondaryStat",
+		"thickness",
+		"detail__source",
+		"detail__target",
 	}
-	for i := range maxConnections {
+	for i := range edgeConnections {
 		flds = append(flds, data.FieldTypeString)
 		names = append(names, "detail__connection_"+strconv.Itoa(i))
 	}
+	flds = append(flds, data.FieldTypeString, data.FieldTypeBool, data.FieldTypeNullableTime, data.FieldTypeNullableTime)
+	names = append(names, "detail__more", "highlighted", "firstSeen", "lastSeen")
 
 	edges := data.NewFrameOfFieldTypes("edges", len(es), flds...)
 	edges.SetFieldNames(names...)
@@ -145,6 +176,12 @@ func nodeFrames(link string, ns, es [][]any, maxConnections int) []*data.Frame {
 		DisplayName: "ID",
 		Path:        "id",
 	}
+	if query.edgeLink != "" {
+		edges.Fields[1].Config.Links = []data.DataLink{{
+			Title: "Connections",
+			URL:   query.edgeLink,
+		}}
+	}
 	edges.Fields[2].Config = &data.FieldConfig{
 		DisplayName: "Source_ID",
 		Path:        "source",
@@ -162,24 +199,105 @@ func nodeFrames(link string, ns, es [][]any, maxConnections int) []*data.Frame {
 		}},
 	}
 	edges.Fields[4].Config = &data.FieldConfig{
+		DisplayName: "Connections",
+		Path:        "connections",
+	}
+	edges.Fields[5].Config = &data.FieldConfig{
+		DisplayName: "Traffic",
+		Path:        "traffic",
+		Unit:        "Bps",
+	}
+	edges.Fields[6].Config = &data.FieldConfig{
+		DisplayName: "Thickness",
+		Path:        "thickness",
+	}
+	edges.Fields[7].Config = &data.FieldConfig{
 		DisplayName: "Source",
 		Path:        "self",
 	}
-	edges.Fields[5].Config = &data.FieldConfig{
+	edges.Fields[8].Config = &data.FieldConfig{
 		DisplayName: "Target",
 		Path:        "peer",
 	}
 
-	for i := range maxConnections {
-		edges.Fields[i+6].Config = &data.FieldConfig{
+	for i := range edgeConnections {
+		edges.Fields[i+9].Config = &data.FieldConfig{
 			DisplayName: fmt.Sprintf("Connection %d", i+1),
 			Path:        fmt.Sprintf("connection %d", i+1),
 		}
 	}
+	edges.Fields[edgeConnections+9].Config = &data.FieldConfig{
+		DisplayName: "More",
+		Path:        "more",
+	}
+	edges.Fields[edgeConnections+10].Config = &data.FieldConfig{
+		DisplayName: "Highlighted",
+		Path:        "highlighted",
+	}
+	edges.Fields[edgeConnections+11].Config = &data.FieldConfig{
+		DisplayName: "First Seen",
+		Path:        "firstSeen",
+	}
+	edges.Fields[edgeConnections+12].Config = &data.FieldConfig{
+		DisplayName: "Last Seen",
+		Path:        "lastSeen",
+	}
 
+	// highlight the edges that connect matched nodes
 	for i, e := range es {
-		edges.SetRow(i, append([]any{timestamp}, e...)...)
+		highlighted := matched[e[1].(string)] || matched[e[2].(string)]
+		first, last := sighted(e[0].(string))
+		edges.SetRow(i, append(append([]any{timestamp}, e...), highlighted, first, last)...)
 	}
 
 	return []*data.Frame{nodes, edges}
 }
+
+// connectionsFrames lists all the connections of an edge.
+func connectionsFrames(id string, edges map[[2]Pid][]any) []*data.Frame {
+	var source, target string
+	var conns []string
+	for _, edge := range edges {
+		if edge[0] == id {
+			source, target = edge[3].(string), edge[4].(string)
+			for _, conn := range edge[5:] {
+				conns = append(conns, conn.(string))
+			}
+			break
+		}
+	}
+
+	frame := data.NewFrame("connections",
+		data.NewField("source", nil, make([]string, len(conns))),
+		data.NewField("target", nil, make([]string, len(conns))),
+		data.NewField("connection", nil, conns),
+	)
+	for i := range conns {
+		frame.Fields[0].Set(i, source)
+		frame.Fields[1].Set(i, target)
+	}
+	frame.Fields[0].Config = &data.FieldConfig{
+		DisplayName: "Source",
+		Path:        "self",
+	}
+	frame.Fields[1].Config = &data.FieldConfig{
+		DisplayName: "Target",
+		Path:        "peer",
+	}
+	frame.Fields[2].Config = &data.FieldConfig{
+		DisplayName: "Connection",
+		Path:        "connection",
+	}
+	frame.SetMeta(&data.FrameMeta{
+		Path:                   "connections",
+		PreferredVisualization: data.VisTypeTable,
+	})
+	if len(conns) == 0 {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("edge %q not found", id),
+		})
+	}
+
+	return []*data.Frame{frame}
+}
@@ -5,12 +5,13 @@ package plugin
 import (
 	"cmp"
 	"fmt"
+	"hash/fnv"
 	"math"
-	"net"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -25,50 +26,125 @@ type (
 
 	// query parameters for request.
 	Query struct {
-		pid  Pid
-		link string
+		pid      Pid
+		start    int64 // start time in ms of process identified by node id
+		link     string
+		edgeLink string // drill-down to an edge's connections
+		edge     string // id of edge whose connections to list
+		filters  []string
+		search   string // highlight nodes matching an executable, host, port, or path
+		maxNodes int    // cap on number of nodes
+		maxEdges int    // cap on number of edges
+		// reverse lookup of host names
+		offline  bool              // only consult the hosts file
+		deadline time.Time         // stop waiting for lookups
+		services map[string]string // override service names of ports
+		graph    *graph            // captures the graph built for export
+		seen     sightings         // first and last seen times of the nodes and edges
+		// shortest paths between two nodes
+		from             string
+		to               string
+		connectionWeight int
+		parentWeight     int
+		holder           string // reverse lookup of the processes holding a host, port, or file
+		table            string // table query in place of the node graph
+		recent           bool   // include connections closed in the last hour
+		// statistics shown by process nodes
+		mainStat      string
+		secondaryStat string
+	}
+
+	// edgeStat sums the connections of an edge and their bytes per second.
+	edgeStat struct {
+		connections int
+		rate        float64
 	}
 )
 
 var (
-	// host/proc specify the arc for the circle drawn around a node.
-	// Each arc has a specific color set in its field metadata to create a circle that identifies the node type.
-	hostColor = []any{1.0, 0.0, 0.0, 0.0, 0.0} // red
-	procColor = []any{0.0, 1.0, 0.0, 0.0, 0.0} // blue
-	dataColor = []any{0.0, 0.0, 1.0, 0.0, 0.0} // yellow
-	sockColor = []any{0.0, 0.0, 0.0, 1.0, 0.0} // magenta
-	kernColor = []any{0.0, 0.0, 0.0, 0.0, 1.0} // cyan
-	red       = map[string]any{"mode": "fixed", "fixedColor": "red"}
-	blue      = map[string]any{"mode": "fixed", "fixedColor": "blue"}
-	yellow    = map[string]any{"mode": "fixed", "fixedColor": "yellow"}
-	magenta   = map[string]any{"mode": "fixed", "fixedColor": "magenta"}
-	cyan      = map[string]any{"mode": "fixed", "fixedColor": "cyan"}
+	// nodeDetails define the detail fields of the nodes, shown when hovering over a node.
+	nodeDetails = []struct {
+		name    string
+		display string
+	}{
+		{"user", "User"},
+		{"command", "Command"},
+		{"cwd", "Directory"},
+		{"start", "Start Time"},
+		{"ppid", "Parent PID"},
+		{"session", "Session"},
+		{"host", "Host Name"},
+		{"service", "Service"},
+		{"port", "Port"},
+		{"path", "Path"},
+		{"inode", "Inode"},
+		{"device", "Device"},
+	}
 )
 
-// color defines the color for grafana nodes.
-func color(conn process.Connection) []any {
-	var color []any
-	if conn.Peer.Pid < 0 {
-		color = hostColor
-		// name for listen port is device inode: on linux decimal and on darwin hexadecimal
-		if _, err := strconv.Atoi(conn.Self.Name); err == nil || conn.Self.Name[0:2] == "0x" { // listen socket
-			color = sockColor
-		}
-	} else if conn.Peer.Pid >= math.MaxInt32 {
-		color = dataColor
-		if conn.Type != "REG" && conn.Type != "DIR" {
-			color = kernColor
-		}
-	} else {
-		color = procColor
+const (
+	// edgeConnections is the number of an edge's connections listed in its detail fields.
+	edgeConnections = 5
+
+	// filterHosts omits the remote hosts cluster from the node graph.
+	filterHosts = "hosts"
+	// filterDatas omits the files, sockets, pipes, ... cluster from the node graph.
+	filterDatas = "datas"
+)
+
+// node assembles the values of a node's fields: its id, stats, name, details, icon, and arcs.
+func node(id string, mainStat, secondaryStat, name string, details map[string]string, cls class) []any {
+	n := []any{id, mainStat, secondaryStat, name}
+	for _, detail := range nodeDetails {
+		n = append(n, details[detail.name])
+	}
+	return append(append(n, classes[cls].icon), arcs(cls)...)
+}
+
+// procID identifies a process node by its pid and start time, so that a reused pid identifies a different node.
+func procID(p *process.Process) string {
+	return fmt.Sprintf("%d@%d", p.Pid, p.Starttime.UnixMilli())
+}
+
+// parseProcID returns the pid and start time of a process node id.
+func parseProcID(id string) (Pid, int64, bool) {
+	pid, start, ok := strings.Cut(id, "@")
+	if !ok {
+		return 0, 0, false
+	}
+	p, err := strconv.Atoi(pid)
+	if err != nil || p <= 0 {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return Pid(p), ms, true
+}
+
+// hashID identifies a host or data node by a stable hash of its name.
+func hashID(kind, name string) string {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%s:%016x", kind, h.Sum64())
+}
+
+// nodeID returns the node id for a pid of the graph.
+func nodeID(ids map[Pid]string, tb process.Table, pid Pid) string {
+	if id, ok := ids[pid]; ok {
+		return id
 	}
-	return color
+	if p, ok := tb[pid]; ok {
+		return procID(p)
+	}
+	return pid.String()
 }
 
 // Nodegraph produces the process connections node graph.
-func Nodegraph(link string, queryPid Pid) backend.DataResponse {
+func Nodegraph(query Query) backend.DataResponse {
 	return backend.DataResponse{
-		Frames: process.Nodegraph(Query{pid: queryPid, link: link}),
+		Frames: process.Nodegraph(query),
 	}
 }
 
@@ -82,6 +158,49 @@ func (query Query) Arrow() string {
 	return " -> "
 }
 
+// detailIndex returns the index of a node detail in a node row.
+func detailIndex(name string) int {
+	return 4 + slices.IndexFunc(nodeDetails, func(d struct{ name, display string }) bool { return d.name == name })
+}
+
+// matches reports whether a node's name, stats, or details contain the query's search term. A numeric term is
+// a pid or port, which must match exactly.
+func (query Query) matches(node []any) bool {
+	if query.search == "" {
+		return false
+	}
+	if _, err := strconv.ParseUint(query.search, 10, 32); err == nil {
+		if pid, _, ok := parseProcID(node[0].(string)); ok && strconv.Itoa(int(pid)) == query.search {
+			return true
+		}
+		return slices.Contains(strings.Split(node[detailIndex("port")].(string), ", "), query.search)
+	}
+	term := strings.ToLower(query.search)
+	for _, v := range node[1 : len(node)-len(classes)-1] { // skip id, icon, and arcs
+		if s, ok := v.(string); ok && strings.Contains(strings.ToLower(s), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// omit reports whether the query's filters exclude a node from the graph.
+func (query Query) omit(pid Pid) bool {
+	for _, filter := range query.filters {
+		switch filter {
+		case filterHosts:
+			if pid < 0 {
+				return true
+			}
+		case filterDatas:
+			if pid >= math.MaxInt32 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (query Query) BuildGraph(
 	tb process.Table,
 	itr process.Tree,
@@ -90,7 +209,39 @@ func (query Query) BuildGraph(
 	datas map[Pid][]any,
 	edges map[[2]Pid][]any,
 ) []*data.Frame {
-	maxConnections := 0
+	// record the lifetimes of the connections
+	now := time.Now()
+	track(tb, now)
+
+	// table queries report on the processes' connections instead of graphing them
+	switch query.table {
+	case tableListening:
+		return []*data.Frame{listeningFrame(tb)}
+	case tableShortLived:
+		return []*data.Frame{shortLivedFrame()}
+	}
+
+	// combine the host nodes of each remote host
+	aliases := mergeHosts(hosts, edges)
+
+	// add the connections closed in the last hour
+	if query.recent {
+		query.closed(tb, hosts, edges)
+	}
+
+	// drop nodes and edges excluded by filters
+	for _, nodes := range []map[Pid][]any{hosts, datas} {
+		for pid := range nodes {
+			if query.omit(pid) {
+				delete(nodes, pid)
+			}
+		}
+	}
+	for id := range edges {
+		if query.omit(id[0]) || query.omit(id[1]) {
+			delete(edges, id)
+		}
+	}
 
 	// add process nodes to each cluster, sort connections for tooltip
 	for depth, pid := range itr.All() {
@@ -108,51 +259,268 @@ func (query Query) BuildGraph(
 						return cmp.Compare(a.(string), b.(string))
 					}
 				})
-				if maxConnections < len(edge)-5 {
-					maxConnections = len(edge) - 5
-				}
 			}
 		}
 	}
 
+	// the queried process may have exited and its pid been reused
+	if query.start != 0 {
+		if p, ok := tb[query.pid]; !ok || p.Starttime.UnixMilli() != query.start {
+			frames := nodeFrames(query, nil, nil)
+			frames[0].AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text: fmt.Sprintf("process %d started at %s no longer exists",
+					query.pid, time.UnixMilli(query.start).Format(time.RFC3339),
+				),
+			})
+			return frames
+		}
+	}
+
+	// point the connection edges from client to server
+	query.orient(tb, edges)
+
+	// identify the edges' endpoints by their stable node ids
+	ids := map[Pid]string{}
+	for _, nodes := range []map[Pid][]any{hosts, datas} {
+		for pid, n := range nodes {
+			ids[pid] = n[0].(string)
+		}
+	}
+	for _, nodes := range prcss {
+		for pid, n := range nodes {
+			ids[pid] = n[0].(string)
+		}
+	}
+	for pid, to := range aliases {
+		if id, ok := ids[to]; ok {
+			ids[pid] = id
+		}
+	}
+	for _, edge := range edges {
+		source := nodeID(ids, tb, Pid(edge[1].(int64)))
+		target := nodeID(ids, tb, Pid(edge[2].(int64)))
+		edge[0], edge[1], edge[2] = source+" -> "+target, source, target
+	}
+
+	if query.edge != "" {
+		return connectionsFrames(query.edge, edges)
+	}
+	query.seen = query.sighted(tb, edges, now)
+
+	// show resource usage in the process nodes' arcs
+	query.heat(tb, prcss)
+	pruneSamples(tb)
+
 	// build hosts cluster
-	ns := cluster(tb, hosts)
+	clusters := []nodeCluster{{"hosts", cluster(tb, hosts)}}
 
 	// build processes clusters
 	for depth := range len(prcss) {
-		ns = append(ns, cluster(tb, prcss[depth])...)
+		clusters = append(clusters, nodeCluster{fmt.Sprintf("processes (depth %d)", depth), cluster(tb, prcss[depth])})
 	}
 
 	// build datas (files, sockets, pipes, ...) cluster
-	ns = append(ns, cluster(tb, datas)...)
+	clusters = append(clusters, nodeCluster{"datas", cluster(tb, datas)})
 
-	// add the edges
+	// add the edges with their connection counts and traffic
+	stats := edgeStats(tb, edges, aliases)
 	var es [][]any
 	// for id, edge := range edges { // does sorting improve graph consistency?
-	for _, edge := range gocore.Ordered(edges, func(a, b [2]Pid) int {
+	for id, edge := range gocore.Ordered(edges, func(a, b [2]Pid) int {
 		return cmp.Or(
 			cmp.Compare(a[0], b[0]),
 			cmp.Compare(a[1], b[1]),
 		)
 	}) {
-		es = append(es, edge)
+		st := stats[id]
+		e := append(append(slices.Clone(edge[:3]),
+			int64(st.connections),
+			st.rate,
+			thickness(st.connections, st.rate),
+		), edge[3:5]...)
+		e = append(e, summary(edge[5:])...)
+		es = append(es, e)
 	}
 
-	return nodeFrames(query.link, ns, es, maxConnections)
+	// reduce the graph to the shortest paths between two nodes
+	if query.from != "" && query.to != "" {
+		var err error
+		if clusters, es, err = query.path(tb, ids, clusters, es); err != nil {
+			frames := nodeFrames(query, nil, nil)
+			frames[0].AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     err.Error(),
+			})
+			return frames
+		}
+	}
+
+	// reduce the graph to the processes holding a host, port, or file
+	var holders *data.Frame
+	if query.holder != "" {
+		clusters, es, holders = query.holders(tb, ids, clusters, es)
+	}
+
+	// cap the size of the graph
+	var focus string
+	if p, ok := tb[query.pid]; ok && query.pid > 0 {
+		focus = procID(p)
+	}
+	ns, es, notices := query.limit(focus, clusters, es)
+	if query.graph != nil {
+		query.graph.capture(clusters, ns, es, edges, query.seen)
+	}
+
+	frames := nodeFrames(query, ns, es)
+	frames[0].AppendNotices(notices...)
+	if holders != nil {
+		frames = append(frames, holders)
+	}
+	return frames
+}
+
+// summary bounds the list of an edge's connections to the first edgeConnections, noting how many more
+// connections a drill-down query of the edge would list.
+func summary(conns []any) []any {
+	s := make([]any, edgeConnections+1)
+	for i := range edgeConnections {
+		s[i] = ""
+		if i < len(conns) {
+			s[i] = conns[i]
+		}
+	}
+	s[edgeConnections] = ""
+	if n := len(conns) - edgeConnections; n > 0 {
+		s[edgeConnections] = fmt.Sprintf("and %d more", n)
+	}
+	return s
+}
+
+// parental reports whether an edge row links a parent process to its child, whose connection sorts first.
+func parental(e []any) bool {
+	return strings.HasPrefix(e[8].(string), "parent")
+}
+
+// mergeHosts combines the host nodes that share a node id, i.e. the connections to different ports of the same
+// remote host, into the node of the lowest synthetic pid. The edges of the merged nodes are reassigned to that
+// pid, and the node lists the services of all. The merged pids are returned as aliases of the remaining pid.
+func mergeHosts(hosts map[Pid][]any, edges map[[2]Pid][]any) map[Pid]Pid {
+	merged := map[string]Pid{}
+	for pid, n := range hosts {
+		if m, ok := merged[n[0].(string)]; !ok || pid < m {
+			merged[n[0].(string)] = pid
+		}
+	}
+	aliases := map[Pid]Pid{}
+	if len(merged) == len(hosts) {
+		return aliases
+	}
+
+	service, port := detailIndex("service"), detailIndex("port")
+	for pid, n := range gocore.Ordered(hosts, cmp.Compare) {
+		to := merged[n[0].(string)]
+		if pid == to {
+			continue
+		}
+		aliases[pid] = to
+		m := hosts[to]
+		m[1] = list(m[1].(string), n[1].(string))
+		m[service] = list(m[service].(string), n[service].(string))
+		m[port] = list(m[port].(string), n[port].(string))
+		delete(hosts, pid)
+	}
+
+	for id, edge := range edges {
+		to, ok := aliases[id[0]]
+		if !ok {
+			continue
+		}
+		delete(edges, id)
+		id[0] = to
+		if e, ok := edges[id]; ok {
+			edges[id] = append(e, edge[5:]...)
+		} else {
+			edge[0], edge[1] = fmt.Sprintf("%d -> %d", id[0], id[1]), int64(id[0])
+			edges[id] = edge
+		}
+	}
+	return aliases
+}
+
+// list adds an item to a comma separated list if not already present.
+func list(items, item string) string {
+	if item == "" || slices.Contains(strings.Split(items, ", "), item) {
+		return items
+	}
+	if items == "" {
+		return item
+	}
+	return items + ", " + item
+}
+
+// edgeStats tallies for each edge the number of underlying connections and, where socket counters are
+// available, their combined bytes per second.
+func edgeStats(tb process.Table, edges map[[2]Pid][]any, aliases map[Pid]Pid) map[[2]Pid]edgeStat {
+	rates := socketRates()
+	stats := map[[2]Pid]edgeStat{}
+	for _, p := range tb {
+		for _, conn := range p.Connections {
+			id := [2]Pid{conn.Self.Pid, conn.Peer.Pid} // data and process edges
+			if conn.Peer.Pid < 0 {
+				id = [2]Pid{cmp.Or(aliases[conn.Peer.Pid], conn.Peer.Pid), conn.Self.Pid} // host edges
+			}
+			if _, ok := edges[id]; !ok {
+				continue // the peer's side of an inter-process connection, or filtered
+			}
+			st := stats[id]
+			st.connections += 1
+			if sock, ok := socketKey(conn.Self.Name, conn.Peer.Name); ok {
+				st.rate += rates[sock]
+			}
+			stats[id] = st
+		}
+	}
+	return stats
+}
+
+// thickness scales the width of an edge logarithmically by its connections and bytes per second so that
+// heavy traffic paths stand out without swamping the graph.
+func thickness(connections int, rate float64) float64 {
+	return min(1+math.Log2(1+float64(connections))+math.Log10(1+rate), 10)
 }
 
 func (query Query) HostNode(conn process.Connection) []any {
-	host, port, _ := net.SplitHostPort(conn.Peer.Name)
-	return append([]any{
-		int64(conn.Peer.Pid),
-		conn.Type + ":" + port,
-		gocore.Hostname(host),
-		host,
-	}, color(conn)...)
+	ep := parseEndpoint(conn.Peer.Name)
+	host := ep.address()
+	name := host
+	if ep.kind == endpointInet && ep.host != "*" {
+		name = query.hostname(ep.host)
+	}
+	port := ep.port
+	if (port == "" || port == "0") && listening(conn) {
+		port = parseEndpoint(conn.Self.Name).port // a listen socket's port may be only in its own end
+	}
+	service := conn.Type
+	if port != "" {
+		service += ":" + query.serviceName(conn.Type, port)
+	}
+	id := hashID("host", host) // connections to a remote host share its node
+	if bind, ok := bound(conn); ok {
+		id = hashID("host", conn.Type+":"+bind.address()+":"+bind.port) // each listen socket has its own node
+	}
+	return node(id, service, name, host,
+		map[string]string{
+			"host":    name,
+			"service": service,
+			"port":    port,
+		},
+		nodeClass(conn),
+	)
 }
 
 func (query Query) HostEdge(tb process.Table, conn process.Connection) []any {
-	host, _, _ := net.SplitHostPort(conn.Peer.Name)
+	host := parseEndpoint(conn.Peer.Name).address()
 	return []any{
 		fmt.Sprintf("%d -> %d", conn.Peer.Pid, conn.Self.Pid),
 		int64(conn.Peer.Pid),
@@ -163,12 +531,27 @@ func (query Query) HostEdge(tb process.Table, conn process.Connection) []any {
 }
 
 func (query Query) DataNode(conn process.Connection) []any {
-	return append([]any{
-		int64(conn.Peer.Pid),
-		conn.Type,
-		conn.Peer.Name,
-		conn.Type + ":" + conn.Peer.Name,
-	}, color(conn)...)
+	inode, device := fileID(conn.Peer.Name)
+	return node(hashID("data", conn.Type+":"+conn.Peer.Name), conn.Type, conn.Peer.Name, conn.Type+":"+conn.Peer.Name,
+		map[string]string{
+			"path":   conn.Peer.Name,
+			"inode":  inode,
+			"device": device,
+		},
+		nodeClass(conn),
+	)
+}
+
+// fileID returns the inode and device of a data connection's file, or for a pipe, socket or other kernel
+// object named type:[inode], just its inode.
+func fileID(name string) (string, string) {
+	if filepath.IsAbs(name) {
+		return fileStat(name)
+	}
+	if i := strings.Index(name, ":["); i >= 0 && strings.HasSuffix(name, "]") {
+		return name[i+2 : len(name)-1], ""
+	}
+	return "", ""
 }
 
 func (query Query) DataEdge(tb process.Table, conn process.Connection) []any {
@@ -183,12 +566,54 @@ func (query Query) DataEdge(tb process.Table, conn process.Connection) []any {
 }
 
 func (query Query) ProcNode(p *process.Process) []any {
-	return append([]any{
-		int64(p.Pid),
-		p.Id.Name,
-		p.Pid.String(),
-		p.Longname(),
-	}, procColor...)
+	mainStat, secondaryStat := cmp.Or(query.mainStat, statName), cmp.Or(query.secondaryStat, statPid)
+	_, main := usage(p, mainStat)
+	_, secondary := usage(p, secondaryStat)
+	return node(procID(p), main, secondary, p.Longname(),
+		map[string]string{
+			"user":    p.Username,
+			"command": strings.Join(p.Args, " "),
+			"cwd":     p.Directory,
+			"start":   p.Starttime.Format(time.RFC3339),
+			"ppid":    p.Ppid.String(),
+			"session": session(p),
+		},
+		classProcess,
+	)
+}
+
+// heat replaces the category color of process nodes with their share of the highest resource usage
+// among the processes, if the query shows a resource usage statistic.
+func (query Query) heat(tb process.Table, prcss map[int]map[Pid][]any) {
+	stat := query.mainStat
+	if !resourceStat(stat) {
+		stat = query.secondaryStat
+	}
+	if !resourceStat(stat) {
+		return
+	}
+
+	values := map[Pid]float64{}
+	var top float64
+	for _, nodes := range prcss {
+		for pid := range nodes {
+			values[pid], _ = usage(tb[pid], stat)
+			top = max(top, values[pid])
+		}
+	}
+
+	for _, nodes := range prcss {
+		for pid, node := range nodes {
+			share := 0.0
+			if top > 0 {
+				share = values[pid] / top
+			}
+			arcs := arcs(classProcess)
+			arcs[classProcess] = share
+			n := len(node) - len(classes)
+			nodes[pid] = append(node[:n:n], arcs...)
+		}
+	}
 }
 
 func (query Query) ProcEdge(tb process.Table, self, peer Pid) []any {
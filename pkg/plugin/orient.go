@@ -0,0 +1,75 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"math"
+	"net/netip"
+	"strings"
+
+	"github.com/zosmac/gomon/process"
+)
+
+// listeners collects the local ports of the listening TCP and TCP6 sockets.
+func listeners(tb process.Table) map[uint16]bool {
+	ports := map[uint16]bool{}
+	for _, p := range tb {
+		for _, conn := range p.Connections {
+			if baseType(conn.Type) != "TCP" || !listening(conn) {
+				continue
+			}
+			for _, name := range []string{conn.Self.Name, conn.Peer.Name} {
+				if ap, err := netip.ParseAddrPort(name); err == nil && ap.Port() != 0 {
+					ports[ap.Port()] = true
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// orient points the host and inter-process edges from client to server, i.e. toward the end of the edge's
+// connections whose local port is listening. A connection to a remote host whose local port is not listening
+// was initiated locally. Parent/child and data edges keep their direction.
+func (query Query) orient(tb process.Table, edges map[[2]Pid][]any) {
+	ports := listeners(tb)
+	for id, edge := range edges {
+		if id[1] >= math.MaxInt32 {
+			continue // data edge
+		}
+		votes := 0 // positive if the connections flow source to target
+		for _, conn := range edge[5:] {
+			source, target, ok := strings.Cut(strings.TrimSuffix(conn.(string), closedSuffix), query.Arrow())
+			if !ok || strings.HasPrefix(source, "parent") {
+				votes = 0
+				break
+			}
+			src, err1 := netip.ParseAddrPort(source)
+			tgt, err2 := netip.ParseAddrPort(target)
+			if err1 != nil || err2 != nil {
+				continue // listen socket or not an ip connection
+			}
+			switch {
+			case ports[tgt.Port()] && !ports[src.Port()]:
+				votes += 1
+			case ports[src.Port()] && !ports[tgt.Port()]:
+				votes -= 1
+			case id[0] < 0 && !ports[tgt.Port()]:
+				votes -= 1 // local end of a host connection is the client
+			}
+		}
+		if votes >= 0 {
+			continue
+		}
+		edge[1], edge[2] = edge[2], edge[1]
+		edge[3], edge[4] = edge[4], edge[3]
+		for i, conn := range edge[5:] {
+			c, closed := strings.CutSuffix(conn.(string), closedSuffix)
+			source, target, _ := strings.Cut(c, query.Arrow())
+			edge[5+i] = target + query.Arrow() + source
+			if closed {
+				edge[5+i] = edge[5+i].(string) + closedSuffix
+			}
+		}
+	}
+}
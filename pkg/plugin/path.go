@@ -0,0 +1,180 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// distance of a node from the path's endpoints, queued for Dijkstra's algorithm.
+	distance struct {
+		id   string
+		dist int
+	}
+
+	// distances is a priority queue of node distances.
+	distances []distance
+
+	// link is an edge of the graph traversed by the path search.
+	link struct {
+		peer   string
+		weight int
+	}
+)
+
+const (
+	// defaultConnectionWeight and defaultParentWeight are the costs of traversing a connection edge and a
+	// parent/child edge on a path, if not specified in the query. Paths prefer actual connections.
+	defaultConnectionWeight = 1
+	defaultParentWeight     = 2
+)
+
+func (q distances) Len() int           { return len(q) }
+func (q distances) Less(i, j int) bool { return q[i].dist < q[j].dist }
+func (q distances) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *distances) Push(x any)        { *q = append(*q, x.(distance)) }
+func (q *distances) Pop() any {
+	old := *q
+	d := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return d
+}
+
+// validPath checks the parameters of a path query: both or neither endpoint, and non-negative weights.
+func validPath(from, to string, connectionWeight, parentWeight int) error {
+	if (from == "") != (to == "") {
+		return fmt.Errorf("path query requires both from and to, got %q and %q", from, to)
+	}
+	if connectionWeight < 0 || parentWeight < 0 {
+		return fmt.Errorf("invalid path weights %d, %d", connectionWeight, parentWeight)
+	}
+	return nil
+}
+
+// endpoints finds the nodes that a path endpoint identifies: a node id, a pid, an executable's path or
+// base name, or a host's address or name.
+func endpoints(spec string, tb process.Table, ids map[Pid]string, clusters []nodeCluster) map[string]bool {
+	matched := map[string]bool{}
+	for _, cl := range clusters {
+		for _, n := range cl.nodes {
+			if n[0].(string) == spec {
+				matched[spec] = true
+				return matched
+			}
+		}
+	}
+	if pid, err := strconv.Atoi(spec); err == nil {
+		if id, ok := ids[Pid(pid)]; ok && pid > 0 && pid < math.MaxInt32 {
+			matched[id] = true
+		}
+		return matched
+	}
+	for pid, p := range tb {
+		if id, ok := ids[pid]; ok && (p.Executable == spec || filepath.Base(p.Executable) == spec) {
+			matched[id] = true
+		}
+	}
+	for _, n := range clusters[0].nodes { // hosts
+		if n[2].(string) == spec || n[3].(string) == spec {
+			matched[n[0].(string)] = true
+		}
+	}
+	return matched
+}
+
+// shortest computes the distance of each node from the nearest of the starting nodes.
+func shortest(adjacent map[string][]link, starts map[string]bool) map[string]int {
+	dist := map[string]int{}
+	q := &distances{}
+	for id := range starts {
+		dist[id] = 0
+		heap.Push(q, distance{id, 0})
+	}
+	for q.Len() > 0 {
+		d := heap.Pop(q).(distance)
+		if d.dist > dist[d.id] {
+			continue // stale entry
+		}
+		for _, l := range adjacent[d.id] {
+			if nd, ok := dist[l.peer]; !ok || d.dist+l.weight < nd {
+				dist[l.peer] = d.dist + l.weight
+				heap.Push(q, distance{l.peer, d.dist + l.weight})
+			}
+		}
+	}
+	return dist
+}
+
+// path reduces the node graph to the nodes and edges on the shortest paths between the query's from and to
+// endpoints. Edges are traversed in either direction, costing the query's parent/child or connection weight.
+func (query Query) path(tb process.Table, ids map[Pid]string, clusters []nodeCluster, es [][]any) ([]nodeCluster, [][]any, error) {
+	from := endpoints(query.from, tb, ids, clusters)
+	if len(from) == 0 {
+		return nil, nil, fmt.Errorf("no node matches path source %q", query.from)
+	}
+	to := endpoints(query.to, tb, ids, clusters)
+	if len(to) == 0 {
+		return nil, nil, fmt.Errorf("no node matches path destination %q", query.to)
+	}
+
+	weight := func(e []any) int {
+		if parental(e) && e[3].(int64) == 0 {
+			return query.parentWeight
+		}
+		return query.connectionWeight
+	}
+	adjacent := map[string][]link{}
+	for _, e := range es {
+		source, target := e[1].(string), e[2].(string)
+		adjacent[source] = append(adjacent[source], link{target, weight(e)})
+		adjacent[target] = append(adjacent[target], link{source, weight(e)})
+	}
+
+	dfrom := shortest(adjacent, from)
+	dto := shortest(adjacent, to)
+	best := -1
+	for id := range to {
+		if d, ok := dfrom[id]; ok && (best < 0 || d < best) {
+			best = d
+		}
+	}
+	if best < 0 {
+		return nil, nil, fmt.Errorf("no path from %q to %q", query.from, query.to)
+	}
+
+	// a node is on a shortest path if its distances from both ends sum to the shortest distance
+	on := func(id string) bool {
+		df, ok1 := dfrom[id]
+		dt, ok2 := dto[id]
+		return ok1 && ok2 && df+dt == best
+	}
+
+	var paths []nodeCluster
+	for _, cl := range clusters {
+		var nodes [][]any
+		for _, n := range cl.nodes {
+			if on(n[0].(string)) {
+				nodes = append(nodes, n)
+			}
+		}
+		paths = append(paths, nodeCluster{cl.name, nodes})
+	}
+
+	var edges [][]any
+	for _, e := range es {
+		source, target := e[1].(string), e[2].(string)
+		if on(source) && on(target) &&
+			(dfrom[source]+weight(e)+dto[target] == best || dfrom[target]+weight(e)+dto[source] == best) {
+			edges = append(edges, e)
+		}
+	}
+
+	return paths, edges, nil
+}
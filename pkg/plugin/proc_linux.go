@@ -0,0 +1,43 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/zosmac/gomon/process"
+)
+
+// fdCount counts a process's open file descriptors.
+func fdCount(p *process.Process) int {
+	fds, err := os.ReadDir("/proc/" + p.Pid.String() + "/fd")
+	if err != nil {
+		return len(p.Connections)
+	}
+	return len(fds)
+}
+
+// session reads a process's session id from /proc/pid/stat.
+func session(p *process.Process) string {
+	stat, err := os.ReadFile("/proc/" + p.Pid.String() + "/stat")
+	if err != nil {
+		return ""
+	}
+	// the command name field may contain spaces, so skip past its closing parenthesis
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if len(fields) < 4 { // state ppid pgrp session
+		return ""
+	}
+	return fields[3]
+}
+
+// major and minor split a device number into its major and minor numbers.
+func major(dev uint64) uint64 {
+	return (dev>>8)&0xfff | (dev>>32)&0xfffff000
+}
+
+func minor(dev uint64) uint64 {
+	return dev&0xff | (dev>>12)&0xffffff00
+}
@@ -0,0 +1,28 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build !linux
+
+package plugin
+
+import (
+	"github.com/zosmac/gomon/process"
+)
+
+// fdCount counts a process's open file descriptors that gomon reports as connections.
+func fdCount(p *process.Process) int {
+	return len(p.Connections)
+}
+
+// session is not reported on this platform.
+func session(p *process.Process) string {
+	return ""
+}
+
+// major and minor split a device number into its major and minor numbers.
+func major(dev uint64) uint64 {
+	return (dev >> 24) & 0xff
+}
+
+func minor(dev uint64) uint64 {
+	return dev & 0xffffff
+}
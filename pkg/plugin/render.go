@@ -0,0 +1,98 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type (
+	// cappedBuffer collects output up to a maximum size. Its buffer is not embedded so that io.Copy
+	// cannot bypass the cap with the buffer's ReadFrom.
+	cappedBuffer struct {
+		buf      bytes.Buffer
+		max      int
+		exceeded bool
+	}
+)
+
+const (
+	// renderTimeout bounds the time Graphviz may take to render the node graph.
+	renderTimeout = 30 * time.Second
+
+	// maxRenderBytes caps the size of a rendered node graph.
+	maxRenderBytes = 32 << 20
+)
+
+var (
+	// errGraphviz reports that Graphviz is not installed.
+	errGraphviz = errors.New("graphviz dot command not found, install Graphviz (https://graphviz.org/download/) to render the node graph")
+
+	// errRenderTimeout reports that Graphviz did not render the node graph in time.
+	errRenderTimeout = fmt.Errorf("graphviz dot timed out after %s", renderTimeout)
+
+	// errRenderSize reports that the rendered node graph exceeds its size cap.
+	errRenderSize = fmt.Errorf("rendered node graph exceeds %d bytes", maxRenderBytes)
+)
+
+// Write appends to the buffer, failing once the output would exceed the maximum size.
+func (buf *cappedBuffer) Write(p []byte) (int, error) {
+	if buf.buf.Len()+len(p) > buf.max {
+		buf.exceeded = true
+		return 0, errRenderSize
+	}
+	return buf.buf.Write(p)
+}
+
+// graphviz returns the path of the Graphviz dot command.
+func graphviz() (string, error) {
+	path, err := exec.LookPath("dot")
+	if err != nil {
+		return "", errGraphviz
+	}
+	return path, nil
+}
+
+// render pipes the node graph's DOT through the Graphviz dot command to produce an image in a format, svg or png.
+func render(format string) resource {
+	return func(ctx context.Context, g *graph, _ url.Values) ([]byte, string, error) {
+		path, err := graphviz()
+		if err != nil {
+			return nil, "", err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+		defer cancel()
+
+		stdout := &cappedBuffer{max: maxRenderBytes}
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, path, "-T"+format)
+		cmd.Stdin = bytes.NewReader(g.dot())
+		cmd.Stdout = stdout
+		cmd.Stderr = &stderr
+		cmd.WaitDelay = time.Second // do not wait on output held open by dot's children once it is killed
+		if err := cmd.Run(); err != nil {
+			switch {
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				return nil, "", errRenderTimeout
+			case stdout.exceeded:
+				return nil, "", errRenderSize
+			default:
+				return nil, "", fmt.Errorf("graphviz dot failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+		}
+
+		contentType := "image/png"
+		if format == "svg" {
+			contentType = "image/svg+xml"
+		}
+		return stdout.buf.Bytes(), contentType, nil
+	}
+}
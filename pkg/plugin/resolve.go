@@ -0,0 +1,197 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zosmac/gocore"
+)
+
+type (
+	// hostEntry caches the result of a reverse lookup of an ip address.
+	hostEntry struct {
+		name    string
+		expires time.Time
+		done    chan struct{} // closed when lookup completes
+	}
+)
+
+const (
+	// hostTTL is how long a resolved host name is cached before being refreshed.
+	hostTTL = 10 * time.Minute
+
+	// negativeTTL is how long a failed lookup is cached before being retried.
+	negativeTTL = time.Minute
+
+	// lookupTimeout bounds each background reverse lookup.
+	lookupTimeout = 5 * time.Second
+
+	// defaultLookupDeadline bounds how long a query waits for its reverse lookups if not configured in the datasource settings.
+	defaultLookupDeadline = 250 * time.Millisecond
+
+	// hostsFile lists static host names for offline resolution.
+	hostsFile = "/etc/hosts"
+
+	// maxHostEntries triggers pruning of expired entries from the cache.
+	maxHostEntries = 4096
+)
+
+var (
+	// resolver caches reverse lookups of host names by ip address, and the static host names of the hosts file.
+	resolver = struct {
+		sync.Mutex
+		entries   map[string]*hostEntry
+		hosts     map[string]string
+		hostsTime time.Time
+	}{
+		entries: map[string]*hostEntry{},
+	}
+)
+
+// hostname returns the host name for an ip address. In offline mode, only the hosts file is consulted. Otherwise,
+// a cached name is returned, refreshing it in the background if expired. An address not yet cached is looked up in
+// the background, waiting until the query's lookup deadline. Until a name is known, the address is returned.
+func (query Query) hostname(addr string) string {
+	if _, err := netip.ParseAddr(addr); err != nil {
+		return addr
+	}
+	if query.offline {
+		if name, ok := staticHost(addr); ok {
+			return name
+		}
+		return addr
+	}
+
+	resolver.Lock()
+	entry, ok := resolver.entries[addr]
+	if !ok {
+		entry = lookup(addr, addr)
+	} else if time.Now().After(entry.expires) && isDone(entry) {
+		stale := entry.name
+		lookup(addr, stale) // refresh in the background, reporting the stale name meanwhile
+		resolver.Unlock()
+		return stale
+	}
+	resolver.Unlock()
+
+	select {
+	case <-entry.done:
+	case <-time.After(time.Until(query.deadline)):
+	}
+
+	resolver.Lock()
+	defer resolver.Unlock()
+	return entry.name
+}
+
+// lookup starts a background reverse lookup of an address, caching the name found. Caller holds resolver lock.
+func lookup(addr, name string) *hostEntry {
+	if len(resolver.entries) >= maxHostEntries {
+		now := time.Now()
+		for a, e := range resolver.entries {
+			if now.After(e.expires) && isDone(e) {
+				delete(resolver.entries, a)
+			}
+		}
+	}
+
+	entry := &hostEntry{
+		name:    name,
+		expires: time.Now().Add(lookupTimeout),
+		done:    make(chan struct{}),
+	}
+	resolver.entries[addr] = entry
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+		defer cancel()
+
+		names, err := net.DefaultResolver.LookupAddr(ctx, addr)
+
+		resolver.Lock()
+		defer resolver.Unlock()
+		if err != nil || len(names) == 0 {
+			if static, ok := staticHostLocked(addr); ok {
+				entry.name = static
+			}
+			entry.expires = time.Now().Add(negativeTTL)
+		} else {
+			entry.name = strings.TrimSuffix(names[0], ".")
+			entry.expires = time.Now().Add(hostTTL)
+		}
+		close(entry.done)
+	}()
+
+	return entry
+}
+
+// isDone reports whether a cache entry's lookup has completed.
+func isDone(entry *hostEntry) bool {
+	select {
+	case <-entry.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// staticHost looks up the name for an address in the hosts file.
+func staticHost(addr string) (string, bool) {
+	resolver.Lock()
+	defer resolver.Unlock()
+	return staticHostLocked(addr)
+}
+
+// staticHostLocked looks up the name for an address in the hosts file, rereading it if modified.
+// Caller holds resolver lock.
+func staticHostLocked(addr string) (string, bool) {
+	if info, err := os.Stat(hostsFile); err == nil && info.ModTime() != resolver.hostsTime {
+		hosts, err := readHosts()
+		if err != nil {
+			gocore.Error("readHosts", err).Err()
+		} else {
+			resolver.hosts = hosts
+			resolver.hostsTime = info.ModTime()
+		}
+	}
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		addr = ip.Unmap().String()
+	}
+	name, ok := resolver.hosts[addr]
+	return name, ok
+}
+
+// readHosts reads the hosts file for the first name of each address.
+func readHosts() (map[string]string, error) {
+	f, err := os.Open(hostsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hosts := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		if _, ok := hosts[ip.Unmap().String()]; !ok {
+			hosts[ip.Unmap().String()] = fields[1]
+		}
+	}
+	return hosts, sc.Err()
+}
@@ -0,0 +1,171 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/zosmac/gocore"
+)
+
+type (
+	// resource renders the node graph for a resource request, returning its content and content type.
+	resource func(ctx context.Context, g *graph, params url.Values) ([]byte, string, error)
+)
+
+var (
+	// resources maps the paths of the resource routes to their renderers.
+	resources = map[string]resource{
+		"graph.dot": func(_ context.Context, g *graph, _ url.Values) ([]byte, string, error) {
+			return g.dot(), "text/vnd.graphviz; charset=utf-8", nil
+		},
+		"graph.svg": render("svg"),
+		"graph.png": render("png"),
+		"graph.graphml": func(_ context.Context, g *graph, _ url.Values) ([]byte, string, error) {
+			return g.graphML(), "application/graphml+xml; charset=utf-8", nil
+		},
+		"graph.mmd": func(_ context.Context, g *graph, _ url.Values) ([]byte, string, error) {
+			return g.mermaid(), "text/vnd.mermaid; charset=utf-8", nil
+		},
+		"graph.json": func(_ context.Context, g *graph, _ url.Values) ([]byte, string, error) {
+			body, err := g.nodeLink()
+			return body, "application/json", err
+		},
+	}
+
+	// rendered identifies the resource routes that Graphviz renders.
+	rendered = map[string]bool{
+		"graph.svg": true,
+		"graph.png": true,
+	}
+)
+
+// resourceQuery builds the node graph query from a resource request's parameters, which match those of a
+// node graph query: pid, node, search, mainStat, secondaryStat, from, to, connectionWeight, parentWeight, lookup,
+// recent, and a comma separated list of filters.
+func resourceQuery(settings Settings, params url.Values) (Query, error) {
+	query := Query{
+		search:        params.Get("search"),
+		mainStat:      params.Get("mainStat"),
+		secondaryStat: params.Get("secondaryStat"),
+		maxNodes:      settings.MaxNodes,
+		maxEdges:      settings.MaxEdges,
+		offline:       settings.OfflineHosts,
+		services:      settings.Services,
+		from:          params.Get("from"),
+		to:            params.Get("to"),
+		holder:        params.Get("lookup"),
+		recent:        params.Get("recent") == "true",
+	}
+	if pid := params.Get("pid"); pid != "" {
+		n, err := strconv.Atoi(pid)
+		if err != nil || n < 0 {
+			return Query{}, fmt.Errorf("invalid pid %q", pid)
+		}
+		query.pid = Pid(n)
+	}
+	if node := params.Get("node"); node != "" {
+		pid, start, ok := parseProcID(node)
+		if !ok {
+			return Query{}, fmt.Errorf("invalid node %q", node)
+		}
+		query.pid, query.start = pid, start
+	}
+	if !validStat(cmp.Or(query.mainStat, statName)) || !validStat(cmp.Or(query.secondaryStat, statPid)) {
+		return Query{}, fmt.Errorf("invalid node stats %q, %q, select from %v", query.mainStat, query.secondaryStat, statNames)
+	}
+	weights := [2]int{defaultConnectionWeight, defaultParentWeight}
+	for i, name := range []string{"connectionWeight", "parentWeight"} {
+		if w := params.Get(name); w != "" {
+			n, err := strconv.Atoi(w)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid %s %q", name, w)
+			}
+			weights[i] = n
+		}
+	}
+	if err := validPath(query.from, query.to, weights[0], weights[1]); err != nil {
+		return Query{}, err
+	}
+	query.connectionWeight, query.parentWeight = weights[0], weights[1]
+	if filters := params.Get("filters"); filters != "" {
+		for _, filter := range strings.Split(filters, ",") {
+			if filter != filterHosts && filter != filterDatas {
+				return Query{}, fmt.Errorf("unknown filter %q", filter)
+			}
+			query.filters = append(query.filters, filter)
+		}
+	}
+	return query, nil
+}
+
+// CallResource of data source serves the node graph in the formats of the resource routes.
+func (instance *Instance) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	gocore.Error("CallResource", nil, map[string]string{
+		"path": req.Path,
+		"url":  req.URL,
+	}).Info()
+
+	rsrc, ok := resources[req.Path]
+	if !ok {
+		return respond(sender, http.StatusNotFound, fmt.Errorf("unknown resource %q", req.Path))
+	}
+	if rendered[req.Path] {
+		if _, err := graphviz(); err != nil {
+			return respond(sender, http.StatusNotImplemented, err)
+		}
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return respond(sender, http.StatusBadRequest, err)
+	}
+	params := u.Query()
+	query, err := resourceQuery(instance.settings, params)
+	if err != nil {
+		return respond(sender, http.StatusBadRequest, err)
+	}
+	query.deadline = time.Now().Add(instance.settings.lookupDeadline())
+
+	g, err := buildGraph(query)
+	if err != nil {
+		return respond(sender, http.StatusNotFound, err)
+	}
+	body, contentType, err := rsrc(ctx, g, params)
+	switch {
+	case errors.Is(err, errGraphviz):
+		return respond(sender, http.StatusNotImplemented, err)
+	case errors.Is(err, errRenderTimeout):
+		return respond(sender, http.StatusGatewayTimeout, err)
+	case err != nil:
+		return respond(sender, http.StatusInternalServerError, err)
+	}
+
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  http.StatusOK,
+		Headers: map[string][]string{"Content-Type": {contentType}},
+		Body:    body,
+	})
+}
+
+// respond reports a failed resource request.
+func respond(sender backend.CallResourceResponseSender, status int, err error) error {
+	gocore.Error("CallResource", err, map[string]string{
+		"status": strconv.Itoa(status),
+	}).Err()
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return sender.Send(&backend.CallResourceResponse{
+		Status:  status,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    body,
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zosmac/gocore"
+)
+
+const (
+	// servicesFile maps well known ports to service names.
+	servicesFile = "/etc/services"
+)
+
+var (
+	// services caches the service names of the services file by port/protocol, e.g. "5432/tcp".
+	services = struct {
+		sync.Mutex
+		names map[string]string
+		mtime time.Time
+	}{}
+)
+
+// serviceName returns the service name of a protocol's port, e.g. "postgresql" for TCP port 5432. The query's
+// overrides, keyed by protocol and port (e.g. "TCP:5432") or port alone, take precedence over the services file.
+// A port without a name is returned as is.
+func (query Query) serviceName(protocol, port string) string {
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return port
+	}
+	protocol = baseType(protocol)
+	if name, ok := query.services[protocol+":"+port]; ok {
+		return name
+	}
+	if name, ok := query.services[port]; ok {
+		return name
+	}
+
+	services.Lock()
+	defer services.Unlock()
+	if info, err := os.Stat(servicesFile); err == nil && info.ModTime() != services.mtime {
+		names, err := readServices()
+		if err != nil {
+			gocore.Error("readServices", err).Err()
+		} else {
+			services.names = names
+			services.mtime = info.ModTime()
+		}
+	}
+	if name, ok := services.names[port+"/"+strings.ToLower(protocol)]; ok {
+		return name
+	}
+	return port
+}
+
+// readServices reads the services file for the name of each port/protocol.
+func readServices() (map[string]string, error) {
+	f, err := os.Open(servicesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.ToLower(fields[1])
+		if _, ok := names[key]; !ok {
+			names[key] = fields[0]
+		}
+	}
+	return names, sc.Err()
+}
@@ -0,0 +1,82 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// sightings record the first and last times that the nodes and edges of the node graph were seen, by id.
+	sightings map[string][2]time.Time
+)
+
+// see widens the first and last seen times of a node or edge.
+func (seen sightings) see(id string, first, last time.Time) {
+	if t, ok := seen[id]; ok {
+		if t[0].Before(first) {
+			first = t[0]
+		}
+		if t[1].After(last) {
+			last = t[1]
+		}
+	}
+	seen[id] = [2]time.Time{first, last}
+}
+
+// endpointPair identifies a connection by its endpoints, in either order.
+func endpointPair(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return a + " <-> " + b
+}
+
+// sighted determines when the nodes and edges of the node graph were first and last seen. A process was first
+// seen when it started. A remote host or connection edge was first seen when its earliest recorded connection
+// opened and last seen when its latest closed, or at the most recent sample if still open. A parent/child edge
+// was first seen when the child started. The lifetimes of data nodes and edges are not recorded.
+func (query Query) sighted(tb process.Table, edges map[[2]Pid][]any, now time.Time) sightings {
+	seen := sightings{}
+	for _, p := range tb {
+		seen.see(procID(p), p.Starttime, now)
+	}
+
+	lts, _ := recorded()
+	conns := sightings{}
+	for _, lt := range lts {
+		conns.see(endpointPair(lt.conn.Self.Name, lt.conn.Peer.Name), lt.firstSeen, lt.lastSeen)
+		if lt.conn.Peer.Pid < 0 {
+			seen.see(hashID("host", parseEndpoint(lt.conn.Peer.Name).address()), lt.firstSeen, lt.lastSeen)
+		}
+	}
+
+	for id, edge := range edges {
+		if id[1] >= math.MaxInt32 {
+			continue // data edge
+		}
+		for _, conn := range edge[5:] {
+			source, target, _ := strings.Cut(strings.TrimSuffix(conn.(string), closedSuffix), query.Arrow())
+			if strings.HasPrefix(source, "parent") {
+				parent, child := tb[id[0]], tb[id[1]]
+				if parent != nil && child != nil {
+					start := parent.Starttime
+					if child.Starttime.After(start) {
+						start = child.Starttime
+					}
+					seen.see(edge[0].(string), start, now)
+				}
+				continue
+			}
+			if t, ok := conns[endpointPair(source, target)]; ok {
+				seen.see(edge[0].(string), t[0], t[1])
+			}
+		}
+	}
+
+	return seen
+}
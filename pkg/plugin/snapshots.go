@@ -0,0 +1,315 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/zosmac/gocore"
+)
+
+type (
+	// storedGraph is the stored form of a node graph, without the data links that a query adds when rendering it.
+	storedGraph struct {
+		Clusters []string                `json:"clusters"`
+		Cluster  map[string]int          `json:"cluster"`
+		Nodes    [][]any                 `json:"nodes"`
+		Edges    [][]any                 `json:"edges"`
+		Seen     map[string][2]time.Time `json:"seen"`
+	}
+
+	// snapshotFile is a stored snapshot, named for the unix milliseconds of its time.
+	snapshotFile struct {
+		time time.Time
+		path string
+		size int64
+	}
+)
+
+const (
+	// pluginID names the plugin's directory of the snapshot store.
+	pluginID = "zosmac-gomon-datasource"
+
+	// snapshotInterval is the interval between stored snapshots of the node graph.
+	snapshotInterval = time.Minute
+
+	// snapshotSuffix names the files of the stored snapshots.
+	snapshotSuffix = ".json.gz"
+
+	// defaultSnapshotMB caps the size of the snapshot store if not configured in the datasource settings.
+	defaultSnapshotMB = 64
+)
+
+var (
+	// errNoSnapshot reports that no snapshot was stored within a query's time range.
+	errNoSnapshot = errors.New("no snapshot stored in the time range")
+
+	// snapshots is the store of node graph snapshots.
+	snapshots = struct {
+		sync.Mutex
+		dir      string
+		maxBytes int64
+		saved    time.Time
+	}{}
+)
+
+// snapshotDir returns the default directory of the snapshot store, outside the plugin's install directory so
+// that it survives upgrades: the plugin's directory under Grafana's data directory if known, else under the user's
+// cache directory.
+func snapshotDir() string {
+	if dir := os.Getenv("GF_PATHS_DATA"); dir != "" {
+		return filepath.Join(dir, pluginID, "snapshots")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, pluginID, "snapshots")
+	}
+	return filepath.Join(os.TempDir(), pluginID, "snapshots")
+}
+
+// openSnapshots configures the snapshot store from the datasource settings.
+func openSnapshots(settings Settings) {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	snapshots.dir = settings.SnapshotDir
+	snapshots.maxBytes = int64(settings.SnapshotMB) << 20
+	if err := os.MkdirAll(snapshots.dir, 0o700); err != nil {
+		gocore.Error("snapshot store", err, map[string]string{
+			"dir": snapshots.dir,
+		}).Err()
+	}
+}
+
+// snapshotDue reports whether it is time to store another snapshot.
+func snapshotDue(now time.Time) bool {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	return snapshots.dir != "" && now.Sub(snapshots.saved) >= snapshotInterval
+}
+
+// saveSnapshot stores a node graph, then removes the oldest snapshots to keep the store's size within its cap.
+// The newest snapshot is always kept.
+func saveSnapshot(now time.Time, g *graph) error {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	snapshots.saved = now
+
+	name := filepath.Join(snapshots.dir, strconv.FormatInt(now.UnixMilli(), 10)+snapshotSuffix)
+	f, err := os.CreateTemp(snapshots.dir, "snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(storedGraph{g.clusters, g.cluster, g.nodes, g.edges, g.seen})
+	err = errors.Join(err, zw.Close(), f.Close())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+
+	files, err := snapshotFiles()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, sf := range files {
+		total += sf.size
+	}
+	for _, sf := range files[:len(files)-1] { // oldest first, keeping the newest
+		if total <= snapshots.maxBytes {
+			break
+		}
+		if err := os.Remove(sf.path); err != nil {
+			return err
+		}
+		total -= sf.size
+	}
+	return nil
+}
+
+// snapshotFiles lists the stored snapshots from oldest to newest. Caller holds snapshots lock.
+func snapshotFiles() ([]snapshotFile, error) {
+	entries, err := os.ReadDir(snapshots.dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []snapshotFile
+	for _, entry := range entries {
+		ms, ok := strings.CutSuffix(entry.Name(), snapshotSuffix)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		n, err := strconv.ParseInt(ms, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, snapshotFile{time.UnixMilli(n), filepath.Join(snapshots.dir, entry.Name()), info.Size()})
+	}
+	slices.SortFunc(files, func(a, b snapshotFile) int {
+		return a.time.Compare(b.time)
+	})
+	return files, nil
+}
+
+// loadSnapshot reads the latest stored snapshot within a time range.
+func loadSnapshot(from, to time.Time) (*graph, time.Time, error) {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+
+	files, err := snapshotFiles()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, err
+	}
+	i := len(files) - 1
+	for ; i >= 0 && files[i].time.After(to); i-- {
+	}
+	if i < 0 || files[i].time.Before(from) {
+		return nil, time.Time{}, errNoSnapshot
+	}
+	sf := files[i]
+
+	f, err := os.Open(sf.path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer zr.Close()
+	var ss storedGraph
+	if err := json.NewDecoder(zr).Decode(&ss); err != nil {
+		return nil, time.Time{}, fmt.Errorf("snapshot %s: %w", sf.path, err)
+	}
+	for _, e := range ss.Edges { // json decodes the connection counts as floats
+		if n, ok := e[3].(float64); ok {
+			e[3] = int64(n)
+		}
+	}
+	return &graph{
+		clusters: ss.Clusters,
+		cluster:  ss.Cluster,
+		nodes:    ss.Nodes,
+		edges:    ss.Edges,
+		seen:     ss.Seen,
+	}, sf.time, nil
+}
+
+// replay reduces a stored node graph for the query's options: the queried process's lineage and connections, and
+// the filters.
+func (query Query) replay(g *graph) ([][]any, [][]any, error) {
+	kept := map[string]bool{}
+	for _, n := range g.nodes {
+		id := n[0].(string)
+		kept[id] = !slices.Contains(query.filters, g.clusters[g.cluster[id]])
+	}
+
+	if query.pid > 0 {
+		var focus string
+		for _, n := range g.nodes {
+			id := n[0].(string)
+			if pid, start, ok := parseProcID(id); ok && pid == query.pid && (query.start == 0 || start == query.start) {
+				focus = id
+				break
+			}
+		}
+		if focus == "" {
+			return nil, nil, fmt.Errorf("process %d not in node graph snapshot", query.pid)
+		}
+
+		// the process, its ancestors and descendants, and their peers
+		lineage := map[string]bool{focus: true}
+		for _, up := range []bool{true, false} {
+			for next := []string{focus}; len(next) > 0; {
+				id := next[0]
+				next = next[1:]
+				for _, e := range g.edges {
+					parent, child := e[1].(string), e[2].(string)
+					if !parental(e) {
+						continue
+					}
+					if up && child == id && !lineage[parent] {
+						lineage[parent] = true
+						next = append(next, parent)
+					} else if !up && parent == id && !lineage[child] {
+						lineage[child] = true
+						next = append(next, child)
+					}
+				}
+			}
+		}
+		related := maps.Clone(lineage)
+		for _, e := range g.edges {
+			if source, target := e[1].(string), e[2].(string); lineage[source] || lineage[target] {
+				related[source], related[target] = true, true
+			}
+		}
+		for id := range kept {
+			kept[id] = kept[id] && related[id]
+		}
+	}
+
+	var ns, es [][]any
+	for _, n := range g.nodes {
+		if kept[n[0].(string)] {
+			ns = append(ns, n)
+		}
+	}
+	for _, e := range g.edges {
+		if kept[e[1].(string)] && kept[e[2].(string)] {
+			es = append(es, e)
+		}
+	}
+	return ns, es, nil
+}
+
+// timeTravel renders the latest stored snapshot within a query's time range, noting the snapshot's time.
+func (query Query) timeTravel(from, to time.Time) data.Frames {
+	g, at, err := loadSnapshot(from, to)
+	var ns, es [][]any
+	if err == nil {
+		ns, es, err = query.replay(g)
+	}
+	if err != nil {
+		frames := nodeFrames(query, nil, nil)
+		frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("node graph snapshot from %s to %s: %v", from.Format(time.RFC3339), to.Format(time.RFC3339), err),
+		})
+		return frames
+	}
+
+	query.seen = g.seen
+	frames := nodeFrames(query, ns, es)
+	frames[0].AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     fmt.Sprintf("node graph snapshot of %s", at.Format(time.RFC3339)),
+	})
+	if query.from != "" || query.holder != "" || query.recent {
+		frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "path, lookup, and recent queries do not apply to node graph snapshots",
+		})
+	}
+	return frames
+}
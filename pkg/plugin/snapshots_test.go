@@ -0,0 +1,122 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testGraph is a node graph of a parent process with a child connected to a remote host, and an unrelated process.
+func testGraph(now time.Time) *graph {
+	edge := func(source, target string, conns int64, conn string) []any {
+		return append([]any{source + " -> " + target, source, target, conns, 0.0, 1.0, source, target}, summary([]any{conn})...)
+	}
+	return &graph{
+		clusters: []string{"hosts", "processes (depth 0)", "processes (depth 1)", "datas"},
+		cluster:  map[string]int{"host:1": 0, "1@1000": 1, "9@1000": 1, "10@2000": 2},
+		nodes: [][]any{
+			node("host:1", "TCP:https", "10.0.0.2", "10.0.0.2", nil, classTCPEstablished),
+			node("1@1000", "init", "1", "init[1]", nil, classProcess),
+			node("9@1000", "cron", "9", "cron[9]", nil, classProcess),
+			node("10@2000", "curl", "10", "curl[10]", nil, classProcess),
+		},
+		edges: [][]any{
+			edge("1@1000", "10@2000", 0, "parent -> child"),
+			edge("10@2000", "host:1", 1, "10.0.0.1:40000 -> 10.0.0.2:443"),
+		},
+		seen: sightings{
+			"10@2000": {now.Add(-time.Hour).Truncate(time.Millisecond), now.Truncate(time.Millisecond)},
+		},
+	}
+}
+
+func TestSnapshotSaveLoad(t *testing.T) {
+	openSnapshots(Settings{SnapshotDir: t.TempDir(), SnapshotMB: 1})
+	now := time.UnixMilli(time.Now().UnixMilli())
+	g := testGraph(now)
+	if err := saveSnapshot(now, g); err != nil {
+		t.Fatalf("saveSnapshot() error = %v", err)
+	}
+
+	loaded, at, err := loadSnapshot(now.Add(-time.Minute), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("loadSnapshot() error = %v", err)
+	}
+	if !at.Equal(now) {
+		t.Errorf("loadSnapshot() time = %s, want %s", at, now)
+	}
+	if !reflect.DeepEqual(loaded.clusters, g.clusters) || !reflect.DeepEqual(loaded.cluster, g.cluster) {
+		t.Errorf("loadSnapshot() clusters = %v %v, want %v %v", loaded.clusters, loaded.cluster, g.clusters, g.cluster)
+	}
+	if !reflect.DeepEqual(loaded.nodes, g.nodes) {
+		t.Errorf("loadSnapshot() nodes = %v, want %v", loaded.nodes, g.nodes)
+	}
+	if !reflect.DeepEqual(loaded.edges, g.edges) {
+		t.Errorf("loadSnapshot() edges = %v, want %v", loaded.edges, g.edges)
+	}
+	if got, want := loaded.seen["10@2000"], g.seen["10@2000"]; !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("loadSnapshot() seen = %v, want %v", got, want)
+	}
+
+	if _, _, err := loadSnapshot(now.Add(-2*time.Hour), now.Add(-time.Hour)); !errors.Is(err, errNoSnapshot) {
+		t.Errorf("loadSnapshot() of an earlier range error = %v, want %v", err, errNoSnapshot)
+	}
+}
+
+func TestSnapshotReplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all", Query{}, []string{"host:1", "1@1000", "9@1000", "10@2000"}},
+		{"pid", Query{pid: 10}, []string{"host:1", "1@1000", "10@2000"}},
+		{"node", Query{pid: 1, start: 1000}, []string{"host:1", "1@1000", "10@2000"}},
+		{"filter", Query{filters: []string{filterHosts}}, []string{"1@1000", "9@1000", "10@2000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns, _, err := tt.query.replay(testGraph(time.Now()))
+			if err != nil {
+				t.Fatalf("replay() error = %v", err)
+			}
+			var got []string
+			for _, n := range ns {
+				got = append(got, n[0].(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replay() nodes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := (Query{pid: 99}).replay(testGraph(time.Now())); err == nil {
+		t.Errorf("replay() of a missing process succeeded")
+	}
+}
+
+func TestTimeTravel(t *testing.T) {
+	openSnapshots(Settings{SnapshotDir: t.TempDir(), SnapshotMB: 1})
+	now := time.Now().Add(-time.Hour)
+	if err := saveSnapshot(now, testGraph(now)); err != nil {
+		t.Fatalf("saveSnapshot() error = %v", err)
+	}
+	query := Query{link: "http://localhost:3000/explore", search: "curl"}
+
+	frames := query.timeTravel(now.Add(-time.Minute), now.Add(time.Minute))
+	if n, _ := frames[0].RowLen(); n != 4 {
+		t.Errorf("timeTravel() has %d nodes, want 4", n)
+	}
+	if links := frames[0].Fields[1].Config.Links; len(links) == 0 || links[0].URL != query.link {
+		t.Errorf("timeTravel() node links = %v, want %q", links, query.link)
+	}
+
+	frames = query.timeTravel(now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	if n, _ := frames[0].RowLen(); n != 0 || len(frames[0].Meta.Notices) == 0 {
+		t.Errorf("timeTravel() of an earlier range has %d nodes and notices %v, want none and a notice", n, frames[0].Meta.Notices)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/zosmac/gocore"
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// socket identifies a connected socket by its local and remote endpoints.
+	socket [2]netip.AddrPort
+)
+
+var (
+	// samples records the most recent cumulative byte counts of the sockets to compute their byte rates.
+	samples = struct {
+		sync.Mutex
+		time  time.Time
+		bytes map[socket]uint64
+	}{
+		bytes: map[socket]uint64{},
+	}
+
+	// unixTypes records which unix domain sockets, by inode and path, are datagram sockets.
+	unixTypes = struct {
+		sync.Mutex
+		time   time.Time
+		dgrams map[string]bool
+	}{}
+)
+
+// unixDgram reports whether a unix domain socket connection is a datagram socket.
+func unixDgram(conn process.Connection) bool {
+	unixTypes.Lock()
+	defer unixTypes.Unlock()
+
+	if time.Since(unixTypes.time) > time.Second {
+		dgrams, err := unixSockets()
+		if err != nil {
+			gocore.Error("unixSockets", err).Err()
+		}
+		unixTypes.time = time.Now()
+		unixTypes.dgrams = dgrams
+	}
+	return unixTypes.dgrams[conn.Self.Name] || unixTypes.dgrams[conn.Peer.Name]
+}
+
+// socketKey derives the socket key for a connection's endpoint names, reporting whether both are ip:port addresses.
+func socketKey(self, peer string) (socket, bool) {
+	local, err := netip.ParseAddrPort(self)
+	if err != nil {
+		return socket{}, false
+	}
+	remote, err := netip.ParseAddrPort(peer)
+	if err != nil {
+		return socket{}, false
+	}
+	return socket{
+		netip.AddrPortFrom(local.Addr().Unmap(), local.Port()),
+		netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port()),
+	}, true
+}
+
+// socketRates samples the cumulative bytes transferred by each socket and returns the bytes per second
+// of each socket since the previous sample. Where the platform does not report socket counters, no rates
+// are returned.
+func socketRates() map[socket]float64 {
+	bytes, err := socketBytes()
+	if err != nil {
+		gocore.Error("socketBytes", err).Err()
+		return nil
+	}
+	now := time.Now()
+
+	samples.Lock()
+	defer samples.Unlock()
+
+	rates := map[socket]float64{}
+	if interval := now.Sub(samples.time).Seconds(); interval > 0 {
+		for sock, n := range bytes {
+			if prev, ok := samples.bytes[sock]; ok && n >= prev {
+				rates[sock] = float64(n-prev) / interval
+			}
+		}
+	}
+	samples.time = now
+	samples.bytes = bytes
+
+	return rates
+}
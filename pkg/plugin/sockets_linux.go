@@ -0,0 +1,166 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/zosmac/gocore"
+)
+
+const (
+	// sockDiagByFamily is the netlink message type of a socket diagnostics request.
+	sockDiagByFamily = 20
+
+	// inetDiagInfo is the inet_diag attribute type that carries the socket's tcp_info.
+	inetDiagInfo = 2
+
+	// sizes and offsets of the kernel's inet_diag structures.
+	sizeofInetDiagReqV2 = 56
+	sizeofInetDiagMsg   = 72
+	offsetBytesAcked    = 120 // tcpi_bytes_acked in struct tcp_info
+	offsetBytesReceived = 128 // tcpi_bytes_received in struct tcp_info
+)
+
+// socketBytes queries the kernel's socket diagnostics for the bytes acknowledged and received by each TCP socket.
+func socketBytes() (map[socket]uint64, error) {
+	bytes := map[socket]uint64{}
+	for _, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		if err := inetDiag(family, bytes); err != nil {
+			return nil, err
+		}
+	}
+	return bytes, nil
+}
+
+// inetDiag dumps the TCP sockets of an address family, recording each socket's byte count.
+func inetDiag(family uint8, bytes map[socket]uint64) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return gocore.Error("socket", err)
+	}
+	defer syscall.Close(fd)
+
+	req := make([]byte, syscall.SizeofNlMsghdr+sizeofInetDiagReqV2)
+	*(*syscall.NlMsghdr)(unsafe.Pointer(&req[0])) = syscall.NlMsghdr{
+		Len:   uint32(len(req)),
+		Type:  sockDiagByFamily,
+		Flags: syscall.NLM_F_REQUEST | syscall.NLM_F_DUMP,
+		Seq:   1,
+	}
+	body := req[syscall.SizeofNlMsghdr:]
+	body[0] = family
+	body[1] = syscall.IPPROTO_TCP
+	body[2] = 1 << (inetDiagInfo - 1)                   // request tcp_info extension
+	binary.NativeEndian.PutUint32(body[4:], ^uint32(0)) // all states
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return gocore.Error("sendto", err)
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return gocore.Error("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return gocore.Error("ParseNetlinkMessage", err)
+		}
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.NLMSG_DONE:
+				return nil
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) >= 4 {
+					if errno := -int32(binary.NativeEndian.Uint32(msg.Data)); errno != 0 {
+						return gocore.Error("inet_diag", syscall.Errno(errno))
+					}
+				}
+				return nil
+			case sockDiagByFamily:
+				if sock, n, ok := inetDiagMsg(msg.Data); ok {
+					bytes[sock] = n
+				}
+			}
+		}
+	}
+}
+
+// inetDiagMsg parses an inet_diag_msg for the socket's endpoints and its bytes acknowledged and received.
+func inetDiagMsg(data []byte) (socket, uint64, bool) {
+	if len(data) < sizeofInetDiagMsg {
+		return socket{}, 0, false
+	}
+
+	family := data[0]
+	id := data[4:] // struct inet_diag_sockid
+	sport := binary.BigEndian.Uint16(id[0:])
+	dport := binary.BigEndian.Uint16(id[2:])
+	var src, dst netip.Addr
+	if family == syscall.AF_INET {
+		src = netip.AddrFrom4([4]byte(id[4:8]))
+		dst = netip.AddrFrom4([4]byte(id[20:24]))
+	} else {
+		src = netip.AddrFrom16([16]byte(id[4:20])).Unmap()
+		dst = netip.AddrFrom16([16]byte(id[20:36])).Unmap()
+	}
+	sock := socket{netip.AddrPortFrom(src, sport), netip.AddrPortFrom(dst, dport)}
+
+	// walk the attributes for tcp_info
+	for attrs := data[sizeofInetDiagMsg:]; len(attrs) >= syscall.SizeofRtAttr; {
+		l := int(binary.NativeEndian.Uint16(attrs[0:]))
+		t := binary.NativeEndian.Uint16(attrs[2:])
+		if l < syscall.SizeofRtAttr || l > len(attrs) {
+			break
+		}
+		if t == inetDiagInfo {
+			info := attrs[syscall.SizeofRtAttr:l]
+			if len(info) < offsetBytesReceived+8 {
+				return socket{}, 0, false
+			}
+			return sock,
+				binary.NativeEndian.Uint64(info[offsetBytesAcked:]) +
+					binary.NativeEndian.Uint64(info[offsetBytesReceived:]),
+				true
+		}
+		l = (l + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if l > len(attrs) {
+			break
+		}
+		attrs = attrs[l:]
+	}
+
+	return socket{}, 0, false
+}
+
+// unixSockets reads /proc/net/unix for the inodes and paths of the datagram sockets.
+func unixSockets() (map[string]bool, error) {
+	f, err := os.Open("/proc/net/unix")
+	if err != nil {
+		return nil, gocore.Error("Open", err)
+	}
+	defer f.Close()
+
+	dgrams := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	sc.Scan() // skip header: Num RefCount Protocol Flags Type St Inode Path
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 7 || fields[4] != "0002" { // SOCK_DGRAM
+			continue
+		}
+		dgrams[fields[6]] = true
+		if len(fields) > 7 {
+			dgrams[fields[7]] = true
+		}
+	}
+	return dgrams, sc.Err()
+}
@@ -0,0 +1,15 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build !linux
+
+package plugin
+
+// socketBytes is not supported on this platform.
+func socketBytes() (map[socket]uint64, error) {
+	return nil, nil
+}
+
+// unixSockets is not supported on this platform.
+func unixSockets() (map[string]bool, error) {
+	return nil, nil
+}
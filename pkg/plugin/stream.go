@@ -3,9 +3,14 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -13,7 +18,204 @@ import (
 	"github.com/zosmac/gocore"
 )
 
-// TODO: flesh this out so it actually works. So far, this is just placeholder code.
+type (
+	// control defines the parameters of a running stream that clients may change by publishing to the stream.
+	control struct {
+		Interval time.Duration
+		Pid      Pid
+		Filters  []string
+	}
+
+	// streamStats records the statistics of a datasource's stream path, reported in its health check details.
+	streamStats struct {
+		Running     bool      `json:"running"`
+		Frames      int       `json:"frames"`
+		SendErrors  int       `json:"sendErrors"`
+		BuildErrors int       `json:"buildErrors"`
+		Consecutive int       `json:"consecutiveErrors"`
+		LastSent    time.Time `json:"lastSent"`
+		LastError   string    `json:"lastError,omitempty"`
+	}
+
+	// controlMessage is the JSON form of a control message published to a stream.
+	controlMessage struct {
+		Interval *string  `json:"interval"` // e.g. "5s"
+		Pid      *Pid     `json:"pid"`
+		Filters  []string `json:"filters"`
+	}
+)
+
+const (
+	// streamInterval is the default interval between a stream's node graphs.
+	streamInterval = 10 * time.Second
+
+	// minStreamInterval is the shortest interval a control message may set.
+	minStreamInterval = time.Second
+
+	// maxSendFailures is the number of consecutive failed sends that terminates a stream.
+	maxSendFailures = 3
+
+	// minBackoff and maxBackoff bound the delay before retrying a failed node graph build.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var (
+	// streams records for each datasource's stream path the most recent frames sent, for the initial data of new
+	// subscribers, and the stream's control parameters.
+	streams = struct {
+		sync.Mutex
+		frames   map[string][]*data.Frame
+		controls map[string]control
+		stats    map[string]*streamStats
+	}{
+		frames:   map[string][]*data.Frame{},
+		controls: map[string]control{},
+		stats:    map[string]*streamStats{},
+	}
+)
+
+// streamLink formats the data link for the nodes of a streamed node graph.
+func streamLink(pctx backend.PluginContext) string {
+	return fmt.Sprintf(`http://localhost:3000/explore?orgId=${__org}&left=["now-5m","now","%s",{"node":"${__value.raw}"}]`,
+		pctx.DataSourceInstanceSettings.Name,
+	)
+}
+
+// streamKey identifies a stream path of a datasource, as every datasource has the same stream paths.
+func streamKey(pctx backend.PluginContext, path string) string {
+	if pctx.DataSourceInstanceSettings == nil {
+		return path
+	}
+	return pctx.DataSourceInstanceSettings.UID + "/" + path
+}
+
+// snapshot returns the most recent frames sent on a datasource's stream path.
+func snapshot(key string) []*data.Frame {
+	streams.Lock()
+	defer streams.Unlock()
+	return streams.frames[key]
+}
+
+// record saves the frames sent on a datasource's stream path as its most recent snapshot.
+func record(key string, frames []*data.Frame) {
+	streams.Lock()
+	defer streams.Unlock()
+	streams.frames[key] = frames
+}
+
+// controls returns the control parameters of a datasource's stream path.
+func controls(key string) control {
+	streams.Lock()
+	defer streams.Unlock()
+	if ctl, ok := streams.controls[key]; ok {
+		return ctl
+	}
+	return control{Interval: streamInterval}
+}
+
+// release drops the control parameters of a datasource's stream path when its stream stops.
+func release(key string) {
+	streams.Lock()
+	defer streams.Unlock()
+	delete(streams.controls, key)
+}
+
+// query returns the node graph query for a stream's control parameters.
+func (ctl control) query(pctx backend.PluginContext, settings Settings) Query {
+	return Query{
+		pid:      ctl.Pid,
+		link:     streamLink(pctx),
+		filters:  ctl.Filters,
+		maxNodes: settings.MaxNodes,
+		maxEdges: settings.MaxEdges,
+		offline:  settings.OfflineHosts,
+		deadline: time.Now().Add(settings.lookupDeadline()),
+		services: settings.Services,
+	}
+}
+
+// count updates the statistics of a datasource's stream path.
+func count(key string, update func(*streamStats)) {
+	streams.Lock()
+	defer streams.Unlock()
+	st, ok := streams.stats[key]
+	if !ok {
+		st = &streamStats{}
+		streams.stats[key] = st
+	}
+	update(st)
+}
+
+// statistics returns a copy of the statistics of each of a datasource's stream paths.
+func statistics(pctx backend.PluginContext) map[string]streamStats {
+	streams.Lock()
+	defer streams.Unlock()
+	prefix := streamKey(pctx, "")
+	stats := map[string]streamStats{}
+	for key, st := range streams.stats {
+		if path, ok := strings.CutPrefix(key, prefix); ok {
+			stats[path] = *st
+		}
+	}
+	return stats
+}
+
+// streamGraph builds a stream's node graph, recovering from a failed build.
+func streamGraph(query Query) (frames []*data.Frame, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("node graph build panic: %v", r)
+		}
+	}()
+
+	frames = Nodegraph(query).Frames
+	if len(frames) == 0 {
+		err = errors.New("node graph build produced no frames")
+	}
+	return
+}
+
+// apply validates a control message and applies it to the control parameters of a datasource's stream path.
+func apply(key string, msg json.RawMessage) (control, error) {
+	var cm controlMessage
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cm); err != nil {
+		return control{}, fmt.Errorf("invalid control message: %w", err)
+	}
+
+	ctl := controls(key)
+	if cm.Interval != nil {
+		interval, err := time.ParseDuration(*cm.Interval)
+		if err != nil {
+			return control{}, fmt.Errorf("invalid interval: %w", err)
+		}
+		if interval < minStreamInterval {
+			return control{}, fmt.Errorf("interval %s shorter than %s", interval, minStreamInterval)
+		}
+		ctl.Interval = interval
+	}
+	if cm.Pid != nil {
+		if *cm.Pid < 0 {
+			return control{}, fmt.Errorf("invalid pid %d", *cm.Pid)
+		}
+		ctl.Pid = *cm.Pid
+	}
+	if cm.Filters != nil {
+		for _, filter := range cm.Filters {
+			if filter != filterHosts && filter != filterDatas {
+				return control{}, fmt.Errorf("unknown filter %q", filter)
+			}
+		}
+		ctl.Filters = cm.Filters
+	}
+
+	streams.Lock()
+	defer streams.Unlock()
+	streams.controls[key] = ctl
+	return ctl, nil
+}
 
 // RunStream initiates data source's stream to channel.
 func (dsi *Instance) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
@@ -23,14 +225,26 @@ func (dsi *Instance) RunStream(ctx context.Context, req *backend.RunStreamReques
 		"request":  fmt.Sprint(*req),
 	}).Info()
 
+	key := streamKey(req.PluginContext, req.Path)
+	defer release(key)
+	count(key, func(st *streamStats) { st.Running = true })
+	defer count(key, func(st *streamStats) { st.Running = false })
+
+	var backoff time.Duration // delay before retrying a failed build
+	failures := 0             // consecutive failed sends
 	for {
+		ctl := controls(key)
+		wait := ctl.Interval
+		if backoff > 0 {
+			wait = backoff
+		}
 		select {
 		case <-ctx.Done():
 			gocore.Error("RunStream Cancelled", nil, map[string]string{
 				"path": req.Path,
 			}).Err()
 			return nil
-		case <-time.After(time.Second * 10):
+		case <-time.After(wait):
 			dsi.Stream.Messages += 1
 			gocore.Error("RunStream", nil, map[string]string{
 				"path":     req.Path,
@@ -39,20 +253,54 @@ func (dsi *Instance) RunStream(ctx context.Context, req *backend.RunStreamReques
 				"request":  fmt.Sprint(*req),
 			}).Info()
 
-			link := fmt.Sprintf(`http://localhost:3000/explore?orgId=${__org}&left=["now-5m","now","%s",{"node":"${__value.raw}"}]`,
-				req.PluginContext.DataSourceInstanceSettings.Name,
-			)
-
-			resp := Nodegraph(link, 0)
-			for _, frame := range resp.Frames {
-				if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
-					gocore.Error("SendFrame", nil, map[string]string{
-						"frame": frame.Name,
-						"err":   err.Error(),
-					}).Err()
-					dsi.Stream.Errors += 1
+			frames, err := streamGraph(ctl.query(req.PluginContext, dsi.settings))
+			if err != nil {
+				backoff = min(max(2*backoff, minBackoff), maxBackoff)
+				count(key, func(st *streamStats) {
+					st.BuildErrors += 1
+					st.LastError = err.Error()
+				})
+				gocore.Error("RunStream build", err, map[string]string{
+					"path":  req.Path,
+					"retry": backoff.String(),
+				}).Err()
+				continue
+			}
+			backoff = 0
+			record(key, frames)
+
+			for _, frame := range frames {
+				if err = sender.SendFrame(frame, data.IncludeAll); err != nil {
 					break
 				}
+				count(key, func(st *streamStats) {
+					st.Frames += 1
+					st.LastSent = time.Now()
+				})
+			}
+
+			if err == nil {
+				failures = 0
+				count(key, func(st *streamStats) { st.Consecutive = 0 })
+				continue
+			}
+
+			dsi.Stream.Errors += 1
+			failures += 1
+			count(key, func(st *streamStats) {
+				st.SendErrors += 1
+				st.Consecutive = failures
+				st.LastError = err.Error()
+			})
+			gocore.Error("SendFrame", err, map[string]string{
+				"path":     req.Path,
+				"failures": strconv.Itoa(failures),
+			}).Err()
+			if failures >= maxSendFailures {
+				return gocore.Error("RunStream terminated", err, map[string]string{
+					"path":     req.Path,
+					"failures": strconv.Itoa(failures),
+				})
 			}
 		}
 	}
@@ -66,14 +314,44 @@ func (dsi *Instance) SubscribeStream(_ context.Context, req *backend.SubscribeSt
 		"request":       fmt.Sprint(*req),
 	}).Info()
 
-	status := backend.SubscribeStreamStatusPermissionDenied
-	if req.Path == "stream" {
+	if req.Path != "stream" {
 		// Allow subscribing only on expected path.
-		status = backend.SubscribeStreamStatusOK
+		return &backend.SubscribeStreamResponse{
+			Status: backend.SubscribeStreamStatusPermissionDenied,
+		}, nil
 	}
-	return &backend.SubscribeStreamResponse{
-		Status: status,
-	}, nil
+
+	// InitialData holds a single frame, so send the nodes of the most recent
+	// snapshot, building one if the stream has yet to send any.
+	key := streamKey(req.PluginContext, req.Path)
+	frames := snapshot(key)
+	if len(frames) == 0 {
+		var err error
+		if frames, err = streamGraph(controls(key).query(req.PluginContext, dsi.settings)); err != nil {
+			gocore.Error("SubscribeStream build", err, map[string]string{
+				"path": req.Path,
+			}).Err()
+		} else {
+			record(key, frames)
+		}
+	}
+
+	resp := &backend.SubscribeStreamResponse{
+		Status: backend.SubscribeStreamStatusOK,
+	}
+	if len(frames) > 0 {
+		initial, err := backend.NewInitialFrame(frames[0], data.IncludeAll)
+		if err != nil {
+			gocore.Error("NewInitialFrame", err, map[string]string{
+				"path":  req.Path,
+				"frame": frames[0].Name,
+			}).Err()
+		} else {
+			resp.InitialData = initial
+		}
+	}
+
+	return resp, nil
 }
 
 // PublishStream sends client message to the stream.
@@ -84,8 +362,34 @@ func (dsi *Instance) PublishStream(_ context.Context, req *backend.PublishStream
 		"request":   fmt.Sprint(*req),
 	}).Info()
 
-	// Do not allow publishing at all.
+	var err error
+	if !dsi.settings.StreamControl {
+		err = errors.New("stream control not enabled in datasource settings")
+	} else if user := req.PluginContext.User; user == nil || (user.Role != "Editor" && user.Role != "Admin") {
+		err = errors.New("stream control requires editor or admin role")
+	} else if req.Path != "stream" {
+		err = fmt.Errorf("unknown stream path %q", req.Path)
+	} else {
+		var ctl control
+		if ctl, err = apply(streamKey(req.PluginContext, req.Path), req.Data); err == nil {
+			gocore.Error("PublishStream control", nil, map[string]string{
+				"path":     req.Path,
+				"interval": ctl.Interval.String(),
+				"pid":      ctl.Pid.String(),
+				"filters":  fmt.Sprint(ctl.Filters),
+			}).Info()
+			return &backend.PublishStreamResponse{
+				Status: backend.PublishStreamStatusOK,
+			}, nil
+		}
+	}
+
+	gocore.Error("PublishStream rejected", err, map[string]string{
+		"path": req.Path,
+	}).Err()
+	reason, _ := json.Marshal(map[string]string{"error": err.Error()})
 	return &backend.PublishStreamResponse{
 		Status: backend.PublishStreamStatusPermissionDenied,
+		Data:   reason,
 	}, nil
 }
@@ -0,0 +1,117 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zosmac/gomon/process"
+)
+
+type (
+	// cpuSample records a process's cumulative CPU time to compute its CPU% over the interval to the next sample.
+	cpuSample struct {
+		start   time.Time
+		total   time.Duration
+		time    time.Time
+		percent float64
+	}
+)
+
+const (
+	// statistics selectable for the main and secondary stats of process nodes.
+	statName = "name"
+	statPid  = "pid"
+	statCPU  = "cpu"
+	statRSS  = "rss"
+	statFds  = "fds"
+
+	// minCPUInterval is the shortest interval between CPU time samples; a process's CPU% is reused within it.
+	minCPUInterval = time.Second
+)
+
+var (
+	// statNames lists the selectable statistics.
+	statNames = []string{statName, statPid, statCPU, statRSS, statFds}
+
+	// cpuSamples records the most recent CPU time sample of each process.
+	cpuSamples = struct {
+		sync.Mutex
+		samples map[Pid]cpuSample
+	}{
+		samples: map[Pid]cpuSample{},
+	}
+)
+
+// validStat reports whether a statistic is selectable for process nodes.
+func validStat(stat string) bool {
+	return slices.Contains(statNames, stat)
+}
+
+// resourceStat reports whether a statistic measures resource usage.
+func resourceStat(stat string) bool {
+	return stat == statCPU || stat == statRSS || stat == statFds
+}
+
+// usage returns the value of a process's statistic and its display form.
+func usage(p *process.Process, stat string) (float64, string) {
+	switch stat {
+	case statPid:
+		return float64(p.Pid), p.Pid.String()
+	case statCPU:
+		cpu := cpuPercent(p)
+		return cpu, fmt.Sprintf("%.1f%%", cpu)
+	case statRSS:
+		return float64(p.Resident), fmt.Sprintf("%.1f MiB", float64(p.Resident)/(1<<20))
+	case statFds:
+		fds := fdCount(p)
+		return float64(fds), fmt.Sprintf("%d fds", fds)
+	default:
+		return 0, p.Id.Name
+	}
+}
+
+// cpuPercent computes a process's CPU% since its previous sample, or over its lifetime if not yet sampled.
+func cpuPercent(p *process.Process) float64 {
+	now := time.Now()
+
+	cpuSamples.Lock()
+	defer cpuSamples.Unlock()
+
+	prev, ok := cpuSamples.samples[p.Pid]
+	if ok && !prev.start.Equal(p.Starttime) {
+		ok = false // pid reused
+	}
+	if ok && now.Sub(prev.time) < minCPUInterval {
+		return prev.percent
+	}
+
+	var percent float64
+	if ok && p.Total >= prev.total {
+		percent = 100 * float64(p.Total-prev.total) / float64(now.Sub(prev.time))
+	} else if elapsed := now.Sub(p.Starttime); elapsed > 0 {
+		percent = 100 * float64(p.Total) / float64(elapsed)
+	}
+	cpuSamples.samples[p.Pid] = cpuSample{
+		start:   p.Starttime,
+		total:   p.Total,
+		time:    now,
+		percent: percent,
+	}
+	return percent
+}
+
+// pruneSamples discards the CPU time samples of processes that have exited.
+func pruneSamples(tb process.Table) {
+	cpuSamples.Lock()
+	defer cpuSamples.Unlock()
+
+	for pid := range cpuSamples.samples {
+		if _, ok := tb[pid]; !ok {
+			delete(cpuSamples.samples, pid)
+		}
+	}
+}
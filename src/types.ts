@@ -7,6 +7,18 @@ export const maxInt32: number = 2**31-1;
 export interface MyQuery extends DataQuery {
   graph?: string;
   pid: number;
+  node?: string;
+  edge?: string;
+  search?: string;
+  mainStat?: string;
+  secondaryStat?: string;
+  from?: string;
+  to?: string;
+  connectionWeight?: number;
+  parentWeight?: number;
+  lookup?: string;
+  table?: string;
+  recent?: boolean;
   streaming: boolean;
 }
 
@@ -21,6 +33,14 @@ export const defaultQuery: MyQuery = {
  * These are options configured for each DataSource instance.
  */
 export interface MyDataSourceOptions extends DataSourceJsonData {
+  streamControl?: boolean;
+  maxNodes?: number;
+  maxEdges?: number;
+  offlineHosts?: boolean;
+  lookupMs?: number;
+  services?: Record<string, string>;
+  snapshotDir?: string;
+  snapshotMB?: number;
 }
 
 export const defaultDataSourceOptions: Partial<MyDataSourceOptions> = {